@@ -0,0 +1,31 @@
+// Package glob matches slash-separated paths against globs whose segments
+// work as in path.Match, plus "**" for any number of segments.  It is the
+// matcher behind aiscan's path policy and keyguard's ignore file.
+package glob
+
+import "path"
+
+// MatchSegments reports whether the path segments name match the glob
+// segments pat: "*", "?" and "[...]" match within one segment, as in
+// path.Match, and a "**" segment matches zero or more segments.  A
+// malformed segment matches nothing.
+func MatchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if MatchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
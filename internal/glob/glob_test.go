@@ -0,0 +1,35 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pat, name string
+		want      bool
+	}{
+		{"a/b.go", "a/b.go", true},
+		{"a/*.go", "a/b.go", true},
+		{"a/*.go", "a/c/b.go", false},
+		{"a/?.go", "a/b.go", true},
+		{"a/[bc].go", "a/c.go", true},
+		{"**/b.go", "b.go", true},
+		{"**/b.go", "a/c/b.go", true},
+		{"a/**", "a", true},
+		{"a/**", "a/b/c", true},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/x/y/c", true},
+		{"a/**/c", "a/x/y/d", false},
+		{"**", "anything/at/all", true},
+		{"a/b", "a/b/c", false},
+		{"a/b/c", "a/b", false},
+		{"a/[", "a/[", false}, // malformed
+	}
+	for _, tt := range tests {
+		if got := MatchSegments(strings.Split(tt.pat, "/"), strings.Split(tt.name, "/")); got != tt.want {
+			t.Errorf("MatchSegments(%q, %q) = %v, want %v", tt.pat, tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,9 +3,13 @@
 package aiscan
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 )
 
 // Scanner decides whether a file's content is likely AI-generated.
@@ -19,6 +23,26 @@ type Scanner interface {
 	Scan(path string, content []byte) (likelyAI bool, confidence float64, err error)
 }
 
+// ContextScanner is implemented by Scanners that can abandon a scan when
+// ctx is cancelled (typically remote backends).  Use ScanContext to call
+// any Scanner with a context.
+type ContextScanner interface {
+	Scanner
+	ScanContext(ctx context.Context, path string, content []byte) (likelyAI bool, confidence float64, err error)
+}
+
+// ScanContext runs s with ctx when s supports it, and plain Scan otherwise
+// (after checking ctx has not already been cancelled).
+func ScanContext(ctx context.Context, s Scanner, path string, content []byte) (bool, float64, error) {
+	if cs, ok := s.(ContextScanner); ok {
+		return cs.ScanContext(ctx, path, content)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+	return s.Scan(path, content)
+}
+
 // NoopScanner always returns (false, 0, nil). It is selected when
 // AI_SCAN_BACKEND=none, disabling AI detection while still allowing
 // the key-presence check to run.
@@ -28,35 +52,116 @@ func (NoopScanner) Scan(_ string, _ []byte) (bool, float64, error) {
 	return false, 0, nil
 }
 
-// FromEnv assembles a Scanner from environment variables:
+func init() {
+	Register("none", func(struct{}, RepoConfig) (Scanner, error) { return NoopScanner{}, nil })
+}
+
+// FromEnv assembles a Scanner from environment variables.  AI_SCAN_BACKEND
+// names a registered backend (see Register and Backends); the built-in
+// ones are
 //
-//	AI_SCAN_BACKEND=none      → NoopScanner
-//	AI_SCAN_BACKEND=http      → HTTPScanner (also selected when AI_SCAN_ENDPOINT is set)
-//	AI_SCAN_BACKEND=heuristic → HeuristicScanner (default)
-//	(unset)                   → HeuristicScanner
+//	none       NoopScanner
+//	heuristic  HeuristicScanner (the default)
+//	http       HTTPScanner (also selected when AI_SCAN_ENDPOINT is set)
+//	ensemble   EnsembleScanner over AI_SCAN_ENSEMBLE members
+//	exec       ExecScanner running AI_SCAN_EXEC
+//	bayes      BayesScanner with the built-in model, or the one at
+//	           AI_SCAN_BAYES_MODEL
+//
+// and each reads its own settings from further AI_SCAN_* variables, listed
+// by Backends (and by check_ai_key -list-backends).
+//
+// Any backend's results are cached on disk (see CachingScanner) when
+//
+//	AI_SCAN_CACHE_DIR    directory for cache entries
+//	AI_SCAN_CACHE_TTL    entry lifetime as a Go duration (default 168h;
+//	                     0 means entries never expire)
+//
+// is set.
+//
+// The heuristic backend is tuned by the file named in AI_SCAN_CONFIG (JSON
+// or YAML, see HeuristicConfig) or, when that is unset, by the
+// "heuristic" key of rc (the repo's .portal-config.yaml "ai-scan" section).
 //
 // The returned Scanner is ready to use; callers pass it down as a plain
-// argument — no global state.
-func FromEnv() (Scanner, error) {
+// argument — no global state.  Some backends hold resources (the exec
+// backend's subprocess); callers should release it with Close when done.
+func FromEnv(rc RepoConfig) (Scanner, error) {
 	backend := strings.ToLower(strings.TrimSpace(os.Getenv("AI_SCAN_BACKEND")))
-	endpoint := strings.TrimSpace(os.Getenv("AI_SCAN_ENDPOINT"))
-
 	// Endpoint env implicitly selects http backend.
-	if endpoint != "" && backend == "" {
+	if backend == "" && strings.TrimSpace(os.Getenv("AI_SCAN_ENDPOINT")) != "" {
 		backend = "http"
 	}
+	s, err := BackendFromEnv(backend, rc)
+	if errors.Is(err, errUnknownBackend) {
+		return nil, fmt.Errorf("AI_SCAN_BACKEND: %w", err)
+	}
+	return s, err
+}
+
+// BackendFromEnv is like FromEnv but builds the named backend (any
+// AI_SCAN_BACKEND value; "" means heuristic) instead of the one
+// AI_SCAN_BACKEND selects.  Callers use it for backends chosen per path by
+// a Policy.  The error for an unregistered name does not say where the
+// name came from; callers add that.
+func BackendFromEnv(backend string, rc RepoConfig) (Scanner, error) {
+	backend = strings.ToLower(strings.TrimSpace(backend))
+	if backend == "" {
+		backend = "heuristic"
+	}
+	s, err := newBackend(backend, rc)
+	if err != nil {
+		return nil, err
+	}
+	return cacheFromEnv(s)
+}
 
-	switch backend {
-	case "none":
-		return NoopScanner{}, nil
-	case "http":
-		if endpoint == "" {
-			return nil, fmt.Errorf("AI_SCAN_BACKEND=http requires AI_SCAN_ENDPOINT to be set")
+// cacheFromEnv wraps s in a CachingScanner when AI_SCAN_CACHE_DIR is set.
+func cacheFromEnv(s Scanner) (Scanner, error) {
+	dir := strings.TrimSpace(os.Getenv("AI_SCAN_CACHE_DIR"))
+	if dir == "" {
+		return s, nil
+	}
+	ttl := 7 * 24 * time.Hour
+	if v := strings.TrimSpace(os.Getenv("AI_SCAN_CACHE_TTL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid AI_SCAN_CACHE_TTL %q (want a Go duration such as 72h)", v)
 		}
-		return &HTTPScanner{Endpoint: endpoint}, nil
-	case "heuristic", "":
-		return &HeuristicScanner{}, nil
-	default:
-		return nil, fmt.Errorf("unknown AI_SCAN_BACKEND %q (valid: none, http, heuristic)", backend)
+		ttl = d
+	}
+	return &CachingScanner{Inner: s, Dir: dir, TTL: ttl}, nil
+}
+
+// RepoConfig is the "ai-scan" section of a repository's .portal-config.yaml:
+//
+//	ai-scan:
+//	  heuristic:
+//	    remove-phrases: ["utilize"]
+//	  policy:
+//	    - paths: ["testdata/**"]
+//	      skip: true
+type RepoConfig struct {
+	Heuristic HeuristicConfig `yaml:"heuristic"`
+	Policy    Policy          `yaml:"policy"`
+
+	// LoadMarkdown is not read from the file: callers fill it in with a
+	// function running BuildMarkdownProfile over the repo's existing docs.
+	// Scanners call it once, on the first Markdown file they score, so
+	// runs without one never pay for reading the docs.
+	LoadMarkdown func() (*MarkdownProfile, error) `yaml:"-"`
+}
+
+// PathPolicy returns the repo's policy rules followed by DefaultPolicy.
+func (rc RepoConfig) PathPolicy() Policy {
+	return append(rc.Policy[:len(rc.Policy):len(rc.Policy)], DefaultPolicy()...)
+}
+
+// Close releases any resources held by s (or, for an ensemble, by its
+// members).  Scanners without resources are left alone.
+func Close(s Scanner) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
 	}
+	return nil
 }
@@ -0,0 +1,180 @@
+package aiscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// File is one input to a batch scan.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// BatchResult is the outcome for one File of a batch.  Err is a per-file
+// failure; the other files of the batch are unaffected.
+type BatchResult struct {
+	Result
+	Err error
+}
+
+// BatchScanner is implemented by Scanners that can scan many files in one
+// round trip.  It is optional: use ScanAll to scan a batch with any
+// Scanner.
+type BatchScanner interface {
+	Scanner
+	// ScanBatch returns one result per file, in input order.  It returns
+	// ErrBatchUnsupported when this instance cannot batch after all (e.g.
+	// no batch endpoint configured); callers then scan file by file.
+	ScanBatch(files []File) ([]BatchResult, error)
+}
+
+// ContextBatchScanner is implemented by BatchScanners that can abandon a
+// batch when ctx is cancelled.  Use ScanAllContext to scan a batch with any
+// Scanner and a context.
+type ContextBatchScanner interface {
+	BatchScanner
+	ScanBatchContext(ctx context.Context, files []File) ([]BatchResult, error)
+}
+
+// ErrBatchUnsupported is returned by ScanBatch implementations that are
+// not configured for batching.
+var ErrBatchUnsupported = errors.New("aiscan: batch scanning not supported")
+
+// ScanAll scans files with s, in one batch when s supports it and file by
+// file (via ScanDetailed) otherwise.  If the batch call itself fails, every
+// file gets that error.
+func ScanAll(s Scanner, files []File) []BatchResult {
+	return ScanAllContext(context.Background(), s, files)
+}
+
+// ScanAllContext is ScanAll with cancellation: ctx reaches s when it is a
+// ContextBatchScanner or, file by file, a ContextScanner, and files not yet
+// scanned when ctx is cancelled get its error.
+func ScanAllContext(ctx context.Context, s Scanner, files []File) []BatchResult {
+	if len(files) == 0 {
+		return nil
+	}
+	if bs, ok := s.(BatchScanner); ok {
+		var (
+			results []BatchResult
+			err     error
+		)
+		if cbs, ok := s.(ContextBatchScanner); ok {
+			results, err = cbs.ScanBatchContext(ctx, files)
+		} else {
+			results, err = bs.ScanBatch(files)
+		}
+		if err == nil {
+			return results
+		}
+		if !errors.Is(err, ErrBatchUnsupported) {
+			results = make([]BatchResult, len(files))
+			for i := range results {
+				results[i].Err = err
+			}
+			return results
+		}
+	}
+	results := make([]BatchResult, len(files))
+	for i, f := range files {
+		results[i].Result, results[i].Err = scanDetailedContext(ctx, s, f.Path, f.Content)
+	}
+	return results
+}
+
+// scanDetailedContext is ScanDetailed, or ScanContext for a ContextScanner
+// without details.
+func scanDetailedContext(ctx context.Context, s Scanner, path string, content []byte) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if _, ok := s.(DetailedScanner); !ok {
+		if cs, ok := s.(ContextScanner); ok {
+			ai, conf, err := cs.ScanContext(ctx, path, content)
+			return Result{LikelyAI: ai, Confidence: conf}, err
+		}
+	}
+	return ScanDetailed(s, path, content)
+}
+
+// batchResponseItem is one element of the batch endpoint's response array.
+type batchResponseItem struct {
+	httpResponse
+	Error string `json:"error,omitempty"`
+}
+
+// defaultBatchSize caps the files per batch request when BatchSize is 0.
+const defaultBatchSize = 50
+
+// ScanBatch POSTs files to BatchEndpoint as a JSON array of
+//
+//	{"path": "<file path>", "content": "<utf-8 text>"}
+//
+// objects and expects a JSON array of the same length and order:
+//
+//	[{"likely_ai": <bool>, "confidence": <float 0-1>}, {"error": "..."}, ...]
+//
+// where an "error" element marks a per-file failure.  Files are sent in
+// chunks of at most BatchSize; a chunk that fails gives each of its files
+// that error, and the other chunks keep their results.  Retries, timeouts
+// and auth work as for Scan.  Without a BatchEndpoint it returns
+// ErrBatchUnsupported.
+func (s *HTTPScanner) ScanBatch(files []File) ([]BatchResult, error) {
+	return s.ScanBatchContext(context.Background(), files)
+}
+
+// ScanBatchContext is ScanBatch with cancellation: ctx aborts the
+// in-flight request and fails the chunks not yet sent.
+func (s *HTTPScanner) ScanBatchContext(ctx context.Context, files []File) ([]BatchResult, error) {
+	if s.BatchEndpoint == "" {
+		return nil, ErrBatchUnsupported
+	}
+	size := s.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+
+	results := make([]BatchResult, len(files))
+	for start := 0; start < len(files); start += size {
+		chunk := files[start:min(start+size, len(files))]
+		if err := s.scanChunk(ctx, chunk, results[start:start+len(chunk)]); err != nil {
+			for i := range chunk {
+				results[start+i].Err = err
+			}
+		}
+	}
+	return results, nil
+}
+
+// scanChunk sends one chunk of a batch and fills in its results.
+func (s *HTTPScanner) scanChunk(ctx context.Context, chunk []File, results []BatchResult) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	reqs := make([]httpRequest, len(chunk))
+	for i, f := range chunk {
+		reqs[i] = httpRequest{Path: f.Path, Content: string(f.Content)}
+	}
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return fmt.Errorf("aiscan/http: marshal batch: %w", err)
+	}
+
+	var items []batchResponseItem
+	if err := s.post(ctx, s.BatchEndpoint, body, &items); err != nil {
+		return err
+	}
+	if len(items) != len(chunk) {
+		return fmt.Errorf("aiscan/http: batch response has %d result(s) for %d file(s)", len(items), len(chunk))
+	}
+	for i, it := range items {
+		results[i] = BatchResult{Result: Result{LikelyAI: it.LikelyAI, Confidence: it.Confidence}}
+		if it.Error != "" {
+			results[i] = BatchResult{Err: fmt.Errorf("aiscan/http: %s: %s", chunk[i].Path, it.Error)}
+		}
+	}
+	return nil
+}
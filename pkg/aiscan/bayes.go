@@ -0,0 +1,307 @@
+package aiscan
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bayesMagic opens the model file's header line.
+const bayesMagic = "aiscan-bayes"
+
+func init() {
+	Register("bayes", func(cfg bayesEnv, _ RepoConfig) (Scanner, error) {
+		load := DefaultBayesModel
+		if cfg.Model != "" {
+			load = func() (*BayesModel, error) { return LoadBayesModel(cfg.Model) }
+		}
+		m, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return &BayesScanner{Model: m}, nil
+	})
+}
+
+// bayesEnv is the bayes backend's config.
+type bayesEnv struct {
+	Model string `env:"AI_SCAN_BAYES_MODEL" help:"model file written by aiscan_bayes train (default: the built-in model)"`
+}
+
+// defaultBayesModel is the built-in model, trained on the small corpus
+// under testdata/bayes.  It is a starting point; a model trained on a
+// repo's own history does better.
+//
+//go:generate go run ../../tools/aiscan_bayes train -human testdata/bayes/human -ai testdata/bayes/ai -o bayes_default.gz
+//go:embed bayes_default.gz
+var defaultBayesModel []byte
+
+// DefaultBayesModel returns the built-in model the bayes backend uses when
+// AI_SCAN_BAYES_MODEL is unset.
+func DefaultBayesModel() (*BayesModel, error) {
+	return ParseBayesModel(defaultBayesModel)
+}
+
+// bayesFormat is the model file format version.
+const bayesFormat = 1
+
+// BayesModel holds the token and bigram counts of a multinomial naive-Bayes
+// classifier trained by TrainBayes.
+//
+// Serialized (WriteTo / ParseBayesModel) it is a gzip-compressed text file:
+// a header line
+//
+//	aiscan-bayes 1 <human docs> <ai docs>
+//
+// followed by one "<human count> <ai count> <feature>" line per feature,
+// sorted by feature.  The output is deterministic, so a model can be
+// committed and diffed, or embedded with go:embed and loaded with
+// ParseBayesModel.
+type BayesModel struct {
+	// Docs counts training documents per class: [0] human, [1] AI.
+	Docs [2]int
+	// Counts maps each feature (a word or "word word" bigram) to its
+	// occurrence counts per class.
+	Counts map[string][2]uint32
+
+	totals [2]uint64 // feature occurrences per class, derived from Counts
+	digest string    // short hash of the serialized model, for Fingerprint
+}
+
+// TrainBayes counts features over samples.  Features seen fewer than
+// minCount times across both classes are dropped to keep the model small.
+func TrainBayes(samples []Sample, minCount int) *BayesModel {
+	m := &BayesModel{Counts: map[string][2]uint32{}}
+	for _, s := range samples {
+		class := 0
+		if s.AI {
+			class = 1
+		}
+		m.Docs[class]++
+		for _, f := range bayesFeatures(Extract(s.Path, s.Content).Prose) {
+			c := m.Counts[f.text]
+			c[class]++
+			m.Counts[f.text] = c
+		}
+	}
+	for f, c := range m.Counts {
+		if int(c[0])+int(c[1]) < minCount {
+			delete(m.Counts, f)
+		}
+	}
+	m.finish()
+	return m
+}
+
+// WriteTo writes the serialized model to w.
+func (m *BayesModel) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	fmt.Fprintf(zw, "%s %d %d %d\n", bayesMagic, bayesFormat, m.Docs[0], m.Docs[1])
+	features := make([]string, 0, len(m.Counts))
+	for f := range m.Counts {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	for _, f := range features {
+		c := m.Counts[f]
+		fmt.Fprintf(zw, "%d %d %s\n", c[0], c[1], f)
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// LoadBayesModel reads a model file written by WriteTo.
+func LoadBayesModel(path string) (*BayesModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("aiscan: read bayes model: %w", err)
+	}
+	return ParseBayesModel(data)
+}
+
+// ParseBayesModel parses a serialized model, e.g. one embedded with
+// go:embed.
+func ParseBayesModel(data []byte) (*BayesModel, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("aiscan: bayes model: %w", err)
+	}
+	sc := bufio.NewScanner(zr)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	m := &BayesModel{Counts: map[string][2]uint32{}}
+	if !sc.Scan() {
+		return nil, fmt.Errorf("aiscan: bayes model: empty")
+	}
+	var magic string
+	var format int
+	if _, err := fmt.Sscanf(sc.Text(), "%s %d %d %d", &magic, &format, &m.Docs[0], &m.Docs[1]); err != nil || magic != bayesMagic {
+		return nil, fmt.Errorf("aiscan: bayes model: bad header %q", sc.Text())
+	}
+	if format != bayesFormat {
+		return nil, fmt.Errorf("aiscan: bayes model: unsupported format %d", format)
+	}
+	for n := 2; sc.Scan(); n++ {
+		parts := strings.SplitN(sc.Text(), " ", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("aiscan: bayes model line %d: malformed", n)
+		}
+		h, err1 := strconv.ParseUint(parts[0], 10, 32)
+		a, err2 := strconv.ParseUint(parts[1], 10, 32)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("aiscan: bayes model line %d: bad counts", n)
+		}
+		m.Counts[parts[2]] = [2]uint32{uint32(h), uint32(a)}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("aiscan: bayes model: %w", err)
+	}
+	m.finish()
+	return m, nil
+}
+
+// finish derives the per-class totals and the digest.
+func (m *BayesModel) finish() {
+	m.totals = [2]uint64{}
+	for _, c := range m.Counts {
+		m.totals[0] += uint64(c[0])
+		m.totals[1] += uint64(c[1])
+	}
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+	sum := sha256.Sum256(buf.Bytes())
+	m.digest = hex.EncodeToString(sum[:8])
+}
+
+// logRatio is the log-likelihood ratio log P(f|AI) - log P(f|human) of
+// one feature, with add-one (Laplace) smoothing.
+func (m *BayesModel) logRatio(f string) float64 {
+	c := m.Counts[f]
+	vocab := float64(len(m.Counts) + 1)
+	pAI := (float64(c[1]) + 1) / (float64(m.totals[1]) + vocab)
+	pHuman := (float64(c[0]) + 1) / (float64(m.totals[0]) + vocab)
+	return math.Log(pAI) - math.Log(pHuman)
+}
+
+// BayesScanner classifies prose with a BayesModel trained on the org's own
+// human- and AI-written files.  Like HeuristicScanner it runs fully offline
+// and only looks at the prose regions returned by Extract.
+//
+// Naive Bayes is badly overconfident on long documents, so the confidence
+// is not the raw posterior: it is logistic(mean log-likelihood ratio ×
+// √features) over the features the model knows, which grows with the
+// amount of evidence but sub-linearly.
+type BayesScanner struct {
+	Model *BayesModel
+	// Threshold is the confidence at which a file is flagged; zero means 0.5.
+	Threshold float64
+}
+
+// bayesTopMatches is how many of the most AI-indicative features
+// ScanDetailed reports.
+const bayesTopMatches = 10
+
+// Fingerprint identifies the model by content.
+func (b *BayesScanner) Fingerprint() string {
+	return fmt.Sprintf("bayes/v%d/%s/%g", bayesFormat, b.Model.digest, b.Threshold)
+}
+
+func (b *BayesScanner) Scan(path string, content []byte) (bool, float64, error) {
+	r, err := b.ScanDetailed(path, content)
+	return r.LikelyAI, r.Confidence, err
+}
+
+// ScanDetailed reports a single "bayes" signal whose matches are the
+// features that pushed hardest towards AI, with their lines.
+func (b *BayesScanner) ScanDetailed(path string, content []byte) (Result, error) {
+	if b.Model == nil {
+		return Result{}, fmt.Errorf("aiscan/bayes: no model loaded")
+	}
+	threshold := b.Threshold
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+
+	// Features the model has never seen carry no evidence, only the bias
+	// of the class sizes, so they are left out.
+	var features []feature
+	for _, f := range bayesFeatures(Extract(path, content).Prose) {
+		if _, ok := b.Model.Counts[f.text]; ok {
+			features = append(features, f)
+		}
+	}
+	if len(features) == 0 {
+		return Result{}, nil
+	}
+
+	type hit struct {
+		f   feature
+		llr float64
+	}
+	var (
+		sum  float64
+		hits []hit
+	)
+	for _, f := range features {
+		llr := b.Model.logRatio(f.text)
+		sum += llr
+		if llr > 0 {
+			hits = append(hits, hit{f, llr})
+		}
+	}
+	n := float64(len(features))
+	conf := 1 / (1 + math.Exp(-sum/n*math.Sqrt(n)))
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].llr > hits[j].llr })
+	var matches []Match
+	seen := map[string]bool{}
+	for _, h := range hits {
+		if len(matches) == bayesTopMatches {
+			break
+		}
+		if !seen[h.f.text] {
+			seen[h.f.text] = true
+			matches = append(matches, Match{Line: h.f.line, Phrase: h.f.text})
+		}
+	}
+
+	return Result{
+		LikelyAI:   conf >= threshold,
+		Confidence: conf,
+		Signals:    []Signal{{Name: "bayes", Value: conf, Weight: 1, Contribution: conf, Matches: matches}},
+	}, nil
+}
+
+// feature is a word or bigram and the line it occurs on.
+type feature struct {
+	text string
+	line int
+}
+
+// bayesFeatures returns the unigrams and same-line bigrams of text.
+func bayesFeatures(text string) []feature {
+	var out []feature
+	for i, line := range strings.Split(text, "\n") {
+		words := proseWords(line)
+		for j, w := range words {
+			out = append(out, feature{w, i + 1})
+			if j > 0 {
+				out = append(out, feature{words[j-1] + " " + w, i + 1})
+			}
+		}
+	}
+	return out
+}
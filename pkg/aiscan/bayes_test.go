@@ -0,0 +1,66 @@
+package aiscan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The embedded model must be what go generate would write today.
+func TestDefaultBayesModelUpToDate(t *testing.T) {
+	m, err := DefaultBayesModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := LoadCorpusDirs("testdata/bayes/human", "testdata/bayes/ai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh := TrainBayes(samples, 2)
+	if m.Docs != fresh.Docs || !reflect.DeepEqual(m.Counts, fresh.Counts) {
+		t.Errorf("bayes_default.gz is stale; run go generate ./pkg/aiscan")
+	}
+}
+
+// The lexical samples are not in the training corpus.
+func TestDefaultBayesModelHeldOut(t *testing.T) {
+	m, err := DefaultBayesModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &BayesScanner{Model: m}
+	files, _ := filepath.Glob("testdata/lexical/*.txt")
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantAI := strings.HasPrefix(filepath.Base(f), "llm")
+		ai, conf, err := s.Scan(f, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ai != wantAI {
+			t.Errorf("%s: likely AI %v (confidence %.2f), want %v", f, ai, conf, wantAI)
+		}
+	}
+}
+
+func TestBayesBackendDefault(t *testing.T) {
+	t.Setenv("AI_SCAN_BAYES_MODEL", "")
+	s, err := newBackend("bayes", RepoConfig{})
+	if err != nil {
+		t.Fatalf("bayes backend without AI_SCAN_BAYES_MODEL: %v", err)
+	}
+	want, _ := DefaultBayesModel()
+	if got := s.(*BayesScanner).Model.digest; got != want.digest {
+		t.Errorf("model digest %s, want the built-in %s", got, want.digest)
+	}
+
+	t.Setenv("AI_SCAN_BAYES_MODEL", filepath.Join(t.TempDir(), "missing.gz"))
+	if _, err := newBackend("bayes", RepoConfig{}); err == nil {
+		t.Errorf("missing AI_SCAN_BAYES_MODEL file accepted")
+	}
+}
@@ -0,0 +1,224 @@
+package aiscan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Fingerprinter is implemented by Scanners whose results depend on
+// configuration.  The fingerprint must change whenever a change of backend,
+// settings or scoring code could change a result, so cached results are
+// never reused across such changes.
+type Fingerprinter interface {
+	Fingerprint() string
+}
+
+// Fingerprint returns s's fingerprint, falling back to its Go type name for
+// Scanners that do not implement Fingerprinter.
+func Fingerprint(s Scanner) string {
+	if f, ok := s.(Fingerprinter); ok {
+		return f.Fingerprint()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// PathSensitive is implemented by Scanners whose result may depend on a
+// file's full path rather than only its content and extension, such as
+// remote detectors that receive the path.
+type PathSensitive interface {
+	PathSensitive() bool
+}
+
+// IsPathSensitive reports whether s says its results depend on the path.
+func IsPathSensitive(s Scanner) bool {
+	p, ok := s.(PathSensitive)
+	return ok && p.PathSensitive()
+}
+
+// PathFingerprinter is implemented by Scanners with settings that only
+// affect some files.  FingerprintPath returns the fingerprint covering
+// everything a scan of path depends on; CachingScanner keys entries on it
+// instead of Fingerprint.
+type PathFingerprinter interface {
+	FingerprintPath(path string) string
+}
+
+// FingerprintPath returns s's fingerprint for path, falling back to
+// Fingerprint for Scanners that do not implement PathFingerprinter.
+func FingerprintPath(s Scanner, path string) string {
+	if f, ok := s.(PathFingerprinter); ok {
+		return f.FingerprintPath(path)
+	}
+	return Fingerprint(s)
+}
+
+// CachingScanner wraps a Scanner and stores its results in a local
+// directory keyed by the SHA-256 of the content, the file extension (which
+// selects the language handling; the whole path for a PathSensitive inner
+// scanner) and the inner scanner's FingerprintPath.  The
+// directory can be persisted between CI runs (e.g. with actions/cache) so
+// unchanged files are not rescanned on every push.
+//
+// Failed scans are never cached.
+type CachingScanner struct {
+	Inner Scanner
+	// Dir holds the cache entries (from AI_SCAN_CACHE_DIR).
+	Dir string
+	// TTL is how long an entry stays valid (from AI_SCAN_CACHE_TTL).
+	// Zero means entries never expire.
+	TTL time.Duration
+
+	hits, misses atomic.Int64
+}
+
+// cacheEntry is the on-disk form of a cached result.
+type cacheEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	Created     time.Time `json:"created"`
+	Result      Result    `json:"result"`
+}
+
+func (c *CachingScanner) Scan(path string, content []byte) (bool, float64, error) {
+	r, err := c.ScanDetailed(path, content)
+	return r.LikelyAI, r.Confidence, err
+}
+
+// ScanDetailed returns the cached Result when there is a fresh entry, and
+// otherwise scans with Inner and stores the outcome.
+func (c *CachingScanner) ScanDetailed(path string, content []byte) (Result, error) {
+	fp := FingerprintPath(c.Inner, path)
+	key := c.key(fp, path, content)
+	if r, ok := c.load(key, fp); ok {
+		c.hits.Add(1)
+		return r, nil
+	}
+	c.misses.Add(1)
+	r, err := ScanDetailed(c.Inner, path, content)
+	if err != nil {
+		return r, err
+	}
+	c.store(key, fp, r)
+	return r, nil
+}
+
+// ScanBatch answers what it can from the cache and sends only the misses to
+// Inner, in one batch if Inner supports it.
+func (c *CachingScanner) ScanBatch(files []File) ([]BatchResult, error) {
+	return c.ScanBatchContext(context.Background(), files)
+}
+
+// ScanBatchContext is ScanBatch with ctx passed on to Inner.
+func (c *CachingScanner) ScanBatchContext(ctx context.Context, files []File) ([]BatchResult, error) {
+	results := make([]BatchResult, len(files))
+	keys := make([]string, len(files))
+	fps := make([]string, len(files))
+	var (
+		missFiles []File
+		missIdx   []int
+	)
+	for i, f := range files {
+		fps[i] = FingerprintPath(c.Inner, f.Path)
+		keys[i] = c.key(fps[i], f.Path, f.Content)
+		if r, ok := c.load(keys[i], fps[i]); ok {
+			c.hits.Add(1)
+			results[i].Result = r
+			continue
+		}
+		c.misses.Add(1)
+		missFiles = append(missFiles, f)
+		missIdx = append(missIdx, i)
+	}
+	for j, r := range ScanAllContext(ctx, c.Inner, missFiles) {
+		i := missIdx[j]
+		results[i] = r
+		if r.Err == nil {
+			c.store(keys[i], fps[i], r.Result)
+		}
+	}
+	return results, nil
+}
+
+// Stats reports the cache hits and misses since the scanner was created.
+func (c *CachingScanner) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Fingerprint is the inner scanner's; caching does not change results.
+func (c *CachingScanner) Fingerprint() string { return Fingerprint(c.Inner) }
+
+// FingerprintPath is the inner scanner's.
+func (c *CachingScanner) FingerprintPath(path string) string { return FingerprintPath(c.Inner, path) }
+
+// PathSensitive reports whether Inner is.
+func (c *CachingScanner) PathSensitive() bool { return IsPathSensitive(c.Inner) }
+
+// Close closes Inner.
+func (c *CachingScanner) Close() error { return Close(c.Inner) }
+
+func (c *CachingScanner) key(fp, path string, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(fp))
+	h.Write([]byte{0})
+	if IsPathSensitive(c.Inner) {
+		h.Write([]byte(path))
+	} else {
+		h.Write([]byte(strings.ToLower(filepath.Ext(path))))
+	}
+	h.Write([]byte{0})
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *CachingScanner) entryPath(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+func (c *CachingScanner) load(key, fp string) (Result, bool) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return Result{}, false
+	}
+	var e cacheEntry
+	if json.Unmarshal(data, &e) != nil || e.Fingerprint != fp {
+		return Result{}, false
+	}
+	if c.TTL > 0 && time.Since(e.Created) > c.TTL {
+		return Result{}, false
+	}
+	return e.Result, true
+}
+
+// store writes an entry atomically.  Failures are ignored: the cache is an
+// optimisation and a read-only or full disk must not fail the scan.
+func (c *CachingScanner) store(key, fp string, r Result) {
+	data, err := json.Marshal(cacheEntry{Fingerprint: fp, Created: time.Now().UTC(), Result: r})
+	if err != nil {
+		return
+	}
+	path := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if err := errors.Join(werr, cerr); err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,96 @@
+package aiscan
+
+import "testing"
+
+// countingScanner counts the scans that reach it.
+type countingScanner struct {
+	scans    int
+	pathSens bool
+}
+
+func (s *countingScanner) Scan(path string, _ []byte) (bool, float64, error) {
+	s.scans++
+	return path == "ai.md", 0.5, nil
+}
+
+func (s *countingScanner) PathSensitive() bool { return s.pathSens }
+
+func TestCachingScannerKey(t *testing.T) {
+	for _, pathSens := range []bool{false, true} {
+		inner := &countingScanner{pathSens: pathSens}
+		c := &CachingScanner{Inner: inner, Dir: t.TempDir()}
+		content := []byte("same content")
+		c.Scan("docs/ai.md", content)
+		ai, _, _ := c.Scan("ai.md", content)
+
+		// Only a path-sensitive scanner is asked again for the same
+		// content and extension at another path.
+		want := 1
+		if pathSens {
+			want = 2
+		}
+		if inner.scans != want {
+			t.Errorf("path-sensitive %v: %d scans, want %d", pathSens, inner.scans, want)
+		}
+		if pathSens && !ai {
+			t.Errorf("path-sensitive result for ai.md came from docs/ai.md's entry")
+		}
+		hits, misses := c.Stats()
+		if hits != int64(2-want) || misses != int64(want) {
+			t.Errorf("path-sensitive %v: Stats = %d, %d", pathSens, hits, misses)
+		}
+	}
+}
+
+func TestIsPathSensitive(t *testing.T) {
+	remote := &EnsembleScanner{Members: []EnsembleMember{
+		{Name: "heuristic", Scanner: &HeuristicScanner{}},
+		{Name: "http", Scanner: &HTTPScanner{}},
+	}}
+	for _, tc := range []struct {
+		s    Scanner
+		want bool
+	}{
+		{&HeuristicScanner{}, false},
+		{&HTTPScanner{}, true},
+		{&ExecScanner{}, true},
+		{remote, true},
+		{&EnsembleScanner{Members: remote.Members[:1]}, false},
+		{&CachingScanner{Inner: &RelativeScanner{Inner: remote}}, true},
+	} {
+		if got := IsPathSensitive(tc.s); got != tc.want {
+			t.Errorf("IsPathSensitive(%s) = %v, want %v", Fingerprint(tc.s), got, tc.want)
+		}
+	}
+}
+
+// A new Markdown profile only invalidates the cached results of Markdown
+// files.
+func TestCachingScannerMarkdownProfile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"main.go":   []byte("package main\n\n// Entry point.\nfunc main() {}\n"),
+		"README.md": []byte("# Title\n\nSome prose.\n"),
+	}
+	profile := func(docs int) func() (*MarkdownProfile, error) {
+		return func() (*MarkdownProfile, error) { return &MarkdownProfile{Docs: docs}, nil }
+	}
+	for _, run := range []struct {
+		docs         int
+		hits, misses int64
+	}{
+		{docs: 5, hits: 0, misses: 2},
+		{docs: 5, hits: 2, misses: 0},
+		{docs: 6, hits: 1, misses: 1},
+	} {
+		c := &CachingScanner{Inner: &HeuristicScanner{LoadMarkdown: profile(run.docs)}, Dir: dir}
+		for p, content := range files {
+			if _, _, err := c.Scan(p, content); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if hits, misses := c.Stats(); hits != run.hits || misses != run.misses {
+			t.Errorf("profile of %d docs: Stats = %d, %d; want %d, %d", run.docs, hits, misses, run.hits, run.misses)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package aiscan
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commentRatio scores how comment-heavy a code file is.  Hand-written code
+// rarely has more than a quarter of its lines commented; LLM output often
+// annotates nearly every statement.  Files with fewer than 10 code lines
+// score 0.
+func commentRatio(ex Extraction) float64 {
+	if ex.CodeLines < 10 {
+		return 0
+	}
+	ratio := float64(ex.CommentLines) / float64(ex.CommentLines+ex.CodeLines)
+	return math.Max(0, math.Min((ratio-0.25)/0.35, 1.0))
+}
+
+// restatedComments scores the fraction of own-line comments that merely
+// restate the code line that follows them ("// increment the counter" above
+// "counter++").  Each such comment is returned as a Match.  Fewer than three
+// single-line own-line comments score 0.
+func restatedComments(ex Extraction) (float64, []Match) {
+	var matches []Match
+	standalone := 0
+	for i, c := range ex.Comments {
+		if !c.OwnLine || strings.Contains(c.Text, "\n") {
+			continue
+		}
+		// Only the last line of a run of line comments sits directly above
+		// code, and a multi-line run is an explanation, not a restatement.
+		if i > 0 && ex.Comments[i-1].OwnLine && ex.Comments[i-1].Line == c.Line-1 {
+			continue
+		}
+		if i+1 < len(ex.Comments) && ex.Comments[i+1].Line == c.Line+1 {
+			continue
+		}
+		standalone++
+		code, ok := ex.codeText[c.Line+1]
+		if !ok {
+			continue
+		}
+		// Doc comments conventionally open with the declared name
+		// ("// Foo returns …" above "func Foo"); that is not restating.
+		if first, _, _ := strings.Cut(c.Text, " "); isToken(code, first) {
+			continue
+		}
+		words := commentWords(c.Text)
+		if len(words) == 0 || len(words) > 8 {
+			continue
+		}
+		idents := codeIdentifiers(code)
+		overlap := 0
+		for _, w := range words {
+			if idents.has(w) {
+				overlap++
+			}
+		}
+		if float64(overlap)/float64(len(words)) >= 0.5 {
+			matches = append(matches, Match{Line: c.Line, Phrase: c.Text})
+		}
+	}
+	if standalone < 3 {
+		return 0, nil
+	}
+	return math.Min(float64(len(matches))/float64(standalone)*2, 1.0), matches
+}
+
+// isToken reports whether word appears in code as a whole identifier.
+func isToken(code, word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, tok := range strings.FieldsFunc(code, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' }) {
+		if tok == word {
+			return true
+		}
+	}
+	return false
+}
+
+// commentStopwords are dropped before comparing a comment with code.
+var commentStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "from": true, "into": true,
+	"this": true, "that": true, "with": true, "new": true, "set": true,
+	"get": true, "all": true, "its": true, "our": true,
+}
+
+// commentWords returns the lower-cased content words (3+ letters) of a
+// comment.
+func commentWords(text string) []string {
+	var words []string
+	for _, f := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if len(f) >= 3 && !commentStopwords[f] {
+			words = append(words, f)
+		}
+	}
+	return words
+}
+
+// identSet is the set of words found on a code line.
+type identSet map[string]bool
+
+// has reports whether w names something on the line, either exactly or as
+// the prefix of a longer identifier word ("print" in "println").
+func (s identSet) has(w string) bool {
+	if s[w] {
+		return true
+	}
+	if len(w) < 4 {
+		return false
+	}
+	for id := range s {
+		if strings.HasPrefix(id, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// operatorWords are the verbs a restating comment typically uses for an
+// operator on the line below ("increment the counter" over "counter++").
+var operatorWords = map[string][]string{
+	"++": {"increment"},
+	"--": {"decrement"},
+	":=": {"initialize", "declare", "create"},
+	"+=": {"add", "append"},
+}
+
+// codeIdentifiers splits the identifiers on a code line into lower-cased
+// words, breaking camelCase and snake_case apart, and also keeps a naive
+// singular/plural variant of each so "items" matches "item".
+func codeIdentifiers(code string) identSet {
+	idents := identSet{}
+	add := func(w string) {
+		if w == "" {
+			return
+		}
+		w = strings.ToLower(w)
+		idents[w] = true
+		idents[strings.TrimSuffix(w, "s")] = true
+		idents[w+"s"] = true
+	}
+	for op, words := range operatorWords {
+		if strings.Contains(code, op) {
+			for _, w := range words {
+				idents[w] = true
+			}
+		}
+	}
+	for _, tok := range strings.FieldsFunc(code, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		add(tok)
+		start := 0
+		runes := []rune(tok)
+		for k := 1; k < len(runes); k++ {
+			if unicode.IsUpper(runes[k]) && unicode.IsLower(runes[k-1]) {
+				add(string(runes[start:k]))
+				start = k
+			}
+		}
+		add(string(runes[start:]))
+	}
+	return idents
+}
@@ -0,0 +1,172 @@
+package aiscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultWeights are the built-in per-signal weights of HeuristicScanner.
+// Code-style signals only take part for code files.
+var defaultWeights = map[string]float64{
+	"phrase-density":        0.45,
+	"hedge-density":         0.35,
+	"bullet-header-density": 0.20,
+	"markdown-structure":    0.20,
+	"type-token-ratio":      0.10,
+	"sentence-uniformity":   0.15,
+	"word-length":           0.10,
+	"comment-ratio":         0.10,
+	"restated-comments":     0.15,
+}
+
+// HeuristicConfig tunes a HeuristicScanner.  Every field is optional; zero
+// values keep the built-in behaviour.
+//
+// Example (YAML):
+//
+//	extra-phrases: ["synergy"]
+//	remove-phrases: ["utilize"]
+//	weights:
+//	  bullet-header-density: 0.05
+//	sigmoid: {steepness: 10, midpoint: 0.4}
+//	threshold: 0.6
+type HeuristicConfig struct {
+	// Phrases replaces the built-in LLM-phrase lexicon when non-empty.
+	Phrases []string `json:"phrases,omitempty" yaml:"phrases,omitempty"`
+	// ExtraPhrases are added to the phrase lexicon.
+	ExtraPhrases []string `json:"extra-phrases,omitempty" yaml:"extra-phrases,omitempty"`
+	// RemovePhrases are dropped from the phrase lexicon.
+	RemovePhrases []string `json:"remove-phrases,omitempty" yaml:"remove-phrases,omitempty"`
+
+	// Hedges, ExtraHedges and RemoveHedges do the same for the hedge lexicon.
+	Hedges       []string `json:"hedges,omitempty" yaml:"hedges,omitempty"`
+	ExtraHedges  []string `json:"extra-hedges,omitempty" yaml:"extra-hedges,omitempty"`
+	RemoveHedges []string `json:"remove-hedges,omitempty" yaml:"remove-hedges,omitempty"`
+
+	// Weights overrides individual signal weights by signal name (as shown
+	// in Signal.Name).  A weight of 0 disables the signal.
+	Weights map[string]float64 `json:"weights,omitempty" yaml:"weights,omitempty"`
+
+	Sigmoid SigmoidConfig `json:"sigmoid,omitzero" yaml:"sigmoid,omitempty"`
+
+	// Threshold is the score at which a file is flagged (default 0.5).
+	Threshold float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+}
+
+// SigmoidConfig shapes the logistic squash applied to the weighted score.
+type SigmoidConfig struct {
+	// Steepness is the logistic k (default 8).
+	Steepness float64 `json:"steepness,omitempty" yaml:"steepness,omitempty"`
+	// Midpoint is the raw score mapped to 0.5 (default 0.35).
+	Midpoint float64 `json:"midpoint,omitempty" yaml:"midpoint,omitempty"`
+}
+
+// LoadHeuristicConfig reads a HeuristicConfig from a JSON (.json) or YAML
+// (any other extension) file.  Unknown keys are rejected.
+func LoadHeuristicConfig(path string) (HeuristicConfig, error) {
+	var cfg HeuristicConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("aiscan: read heuristic config: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("aiscan: parse heuristic config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("aiscan: heuristic config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate reports unknown signal names and out-of-range values.
+func (c HeuristicConfig) Validate() error {
+	for name, w := range c.Weights {
+		if _, ok := defaultWeights[name]; !ok {
+			return fmt.Errorf("unknown signal %q in weights", name)
+		}
+		if w < 0 {
+			return fmt.Errorf("negative weight %g for %q", w, name)
+		}
+	}
+	if c.Threshold < 0 || c.Threshold > 1 {
+		return fmt.Errorf("threshold %g outside [0,1]", c.Threshold)
+	}
+	if c.Sigmoid.Steepness < 0 {
+		return fmt.Errorf("negative sigmoid steepness %g", c.Sigmoid.Steepness)
+	}
+	return nil
+}
+
+func (c HeuristicConfig) phrases() []string {
+	return lexicon(defaultPhrases, c.Phrases, c.ExtraPhrases, c.RemovePhrases)
+}
+
+func (c HeuristicConfig) hedges() []string {
+	return lexicon(defaultHedges, c.Hedges, c.ExtraHedges, c.RemoveHedges)
+}
+
+func (c HeuristicConfig) weight(signal string) float64 {
+	if w, ok := c.Weights[signal]; ok {
+		return w
+	}
+	return defaultWeights[signal]
+}
+
+func (c HeuristicConfig) threshold() float64 {
+	if c.Threshold > 0 {
+		return c.Threshold
+	}
+	return 0.5
+}
+
+func (s SigmoidConfig) norm(x float64) float64 {
+	if s.Steepness == 0 && s.Midpoint == 0 {
+		return sigmoidNorm(x)
+	}
+	k, x0 := s.Steepness, s.Midpoint
+	if k == 0 {
+		k = 8.0
+	}
+	if x0 == 0 {
+		x0 = 0.35
+	}
+	return sigmoidCurve(x, k, x0)
+}
+
+// lexicon builds an effective phrase list: base (or replace, when set),
+// plus extra, minus remove.  Entries are lower-cased to match the
+// lower-cased text they are compared against.
+func lexicon(base, replace, extra, remove []string) []string {
+	if len(replace) > 0 {
+		base = replace
+	}
+	drop := map[string]bool{}
+	for _, r := range remove {
+		drop[strings.ToLower(strings.TrimSpace(r))] = true
+	}
+	var out []string
+	for _, list := range [][]string{base, extra} {
+		for _, p := range list {
+			p = strings.ToLower(strings.TrimSpace(p))
+			if p != "" && !drop[p] {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package aiscan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sample is one labeled document of an evaluation or training corpus.
+type Sample struct {
+	Path    string
+	Content []byte
+	AI      bool
+}
+
+// LoadCorpusDirs reads every regular file under humanDir (labeled human)
+// and aiDir (labeled AI).  Either directory may be empty to skip it.
+// Hidden files and directories are ignored.
+func LoadCorpusDirs(humanDir, aiDir string) ([]Sample, error) {
+	var samples []Sample
+	for _, d := range []struct {
+		dir string
+		ai  bool
+	}{{humanDir, false}, {aiDir, true}} {
+		if d.dir == "" {
+			continue
+		}
+		err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != d.dir && strings.HasPrefix(entry.Name(), ".") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, Sample{Path: path, Content: content, AI: d.ai})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("aiscan: load corpus %s: %w", d.dir, err)
+		}
+	}
+	return samples, nil
+}
+
+// manifestLine is one line of a JSONL corpus manifest.
+type manifestLine struct {
+	Path    string  `json:"path"`
+	Label   string  `json:"label"`
+	Content *string `json:"content,omitempty"`
+}
+
+// LoadCorpusManifest reads a JSONL manifest, one sample per line:
+//
+//	{"path": "docs/intro.md", "label": "human"}
+//	{"path": "gen/1.md", "label": "ai", "content": "inline text…"}
+//
+// label is "ai" or "human".  Without "content", the file at path (relative
+// to the manifest's directory) is read.  Blank lines and lines starting
+// with "#" are skipped.
+func LoadCorpusManifest(manifest string) ([]Sample, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("aiscan: open manifest: %w", err)
+	}
+	defer f.Close()
+
+	base := filepath.Dir(manifest)
+	var samples []Sample
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var m manifestLine
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			return nil, fmt.Errorf("aiscan: %s:%d: %w", manifest, n, err)
+		}
+		var ai bool
+		switch strings.ToLower(m.Label) {
+		case "ai":
+			ai = true
+		case "human":
+		default:
+			return nil, fmt.Errorf("aiscan: %s:%d: label %q (want ai or human)", manifest, n, m.Label)
+		}
+		s := Sample{Path: m.Path, AI: ai}
+		if m.Content != nil {
+			s.Content = []byte(*m.Content)
+		} else {
+			path := m.Path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(base, path)
+			}
+			if s.Content, err = os.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("aiscan: %s:%d: %w", manifest, n, err)
+			}
+		}
+		samples = append(samples, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("aiscan: read manifest: %w", err)
+	}
+	return samples, nil
+}
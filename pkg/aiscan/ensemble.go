@@ -0,0 +1,289 @@
+package aiscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("ensemble", newEnsembleScanner)
+}
+
+// ensembleEnv is the ensemble backend's config.
+type ensembleEnv struct {
+	Members  string       `env:"AI_SCAN_ENSEMBLE" help:"members with optional weights, e.g. heuristic:1,http:2; default heuristic, plus http when AI_SCAN_ENDPOINT is set"`
+	Mode     EnsembleMode `env:"AI_SCAN_ENSEMBLE_MODE" default:"weighted" help:"weighted | max | quorum"`
+	Quorum   int          `env:"AI_SCAN_ENSEMBLE_QUORUM" help:"members that must agree in quorum mode (default: simple majority)"`
+	Endpoint string       `env:"AI_SCAN_ENDPOINT" help:"see the http backend"`
+}
+
+func newEnsembleScanner(cfg ensembleEnv, rc RepoConfig) (Scanner, error) {
+	spec := cfg.Members
+	if spec == "" {
+		spec = "heuristic"
+		if cfg.Endpoint != "" {
+			spec += ",http"
+		}
+	}
+	members, err := parseEnsembleSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("AI_SCAN_ENSEMBLE: %w", err)
+	}
+	for i := range members {
+		if members[i].Name == "ensemble" {
+			return nil, fmt.Errorf("AI_SCAN_ENSEMBLE: ensembles cannot be nested")
+		}
+		s, err := newBackend(members[i].Name, rc)
+		if err != nil {
+			for _, built := range members[:i] {
+				Close(built.Scanner)
+			}
+			return nil, fmt.Errorf("AI_SCAN_ENSEMBLE member %q: %w", members[i].Name, err)
+		}
+		members[i].Scanner = s
+	}
+
+	mode := EnsembleMode(strings.ToLower(string(cfg.Mode)))
+	switch mode {
+	case EnsembleWeighted, EnsembleMax, EnsembleQuorum:
+	default:
+		return nil, fmt.Errorf("unknown AI_SCAN_ENSEMBLE_MODE %q (valid: weighted, max, quorum)", mode)
+	}
+	if cfg.Quorum < 0 {
+		return nil, fmt.Errorf("invalid AI_SCAN_ENSEMBLE_QUORUM %d", cfg.Quorum)
+	}
+	if cfg.Quorum > len(members) {
+		return nil, fmt.Errorf("AI_SCAN_ENSEMBLE_QUORUM=%d exceeds the %d configured member(s)", cfg.Quorum, len(members))
+	}
+
+	return &EnsembleScanner{Members: members, Mode: mode, Quorum: cfg.Quorum}, nil
+}
+
+// EnsembleMode selects how an EnsembleScanner combines member results.
+type EnsembleMode string
+
+const (
+	// EnsembleWeighted averages member confidences by weight and flags the
+	// file when the average reaches the threshold.
+	EnsembleWeighted EnsembleMode = "weighted"
+	// EnsembleMax takes the highest member confidence, so any single
+	// member can flag a file on its own.
+	EnsembleMax EnsembleMode = "max"
+	// EnsembleQuorum flags the file when at least Quorum members flag it.
+	EnsembleQuorum EnsembleMode = "quorum"
+)
+
+// EnsembleMember is one backend inside an EnsembleScanner.
+type EnsembleMember struct {
+	// Name identifies the member in error messages (e.g. "heuristic").
+	Name    string
+	Scanner Scanner
+	// Weight is the member's share of the weighted average.  Zero or
+	// negative weights are treated as 1.
+	Weight float64
+}
+
+// EnsembleScanner runs several Scanners over the same file and combines
+// their verdicts.  A member that returns an error is left out of the vote
+// (its weight is dropped from the average); the ensemble only fails when
+// every member fails.
+type EnsembleScanner struct {
+	Members []EnsembleMember
+	Mode    EnsembleMode
+	// Quorum is the number of members that must flag a file in
+	// EnsembleQuorum mode.  Zero means a simple majority of the members
+	// that answered.
+	Quorum int
+	// Threshold is the confidence at which the combined score counts as
+	// likely AI in weighted and max modes.  Zero means 0.5.
+	Threshold float64
+}
+
+func (e *EnsembleScanner) Scan(path string, content []byte) (bool, float64, error) {
+	r, err := e.ScanDetailed(path, content)
+	return r.LikelyAI, r.Confidence, err
+}
+
+// ScanDetailed reports one signal per answering member (its confidence and
+// weight) followed by that member's own signals, prefixed "<member>/".
+func (e *EnsembleScanner) ScanDetailed(path string, content []byte) (Result, error) {
+	if len(e.Members) == 0 {
+		return Result{}, fmt.Errorf("aiscan/ensemble: no members configured")
+	}
+	answers := make([]BatchResult, len(e.Members))
+	for i, m := range e.Members {
+		answers[i].Result, answers[i].Err = ScanDetailed(m.Scanner, path, content)
+	}
+	return e.combine(answers)
+}
+
+// ScanBatch scans the batch with every member, letting members that batch
+// (such as an HTTPScanner with a batch endpoint) make one round trip, and
+// combines the answers per file.
+func (e *EnsembleScanner) ScanBatch(files []File) ([]BatchResult, error) {
+	return e.ScanBatchContext(context.Background(), files)
+}
+
+// ScanBatchContext is ScanBatch with ctx passed on to every member.
+func (e *EnsembleScanner) ScanBatchContext(ctx context.Context, files []File) ([]BatchResult, error) {
+	if len(e.Members) == 0 {
+		return nil, fmt.Errorf("aiscan/ensemble: no members configured")
+	}
+	perMember := make([][]BatchResult, len(e.Members))
+	for i, m := range e.Members {
+		perMember[i] = ScanAllContext(ctx, m.Scanner, files)
+	}
+	results := make([]BatchResult, len(files))
+	for f := range files {
+		answers := make([]BatchResult, len(e.Members))
+		for i := range e.Members {
+			answers[i] = perMember[i][f]
+		}
+		results[f].Result, results[f].Err = e.combine(answers)
+	}
+	return results, nil
+}
+
+// combine merges the members' answers (indexed like Members) for one file.
+func (e *EnsembleScanner) combine(answers []BatchResult) (Result, error) {
+	threshold := e.Threshold
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+
+	var (
+		total, wsum float64
+		maxConf     float64
+		votes, seen int
+		errs        []error
+		signals     []Signal
+		memberSigs  [][]Signal
+	)
+	for i, m := range e.Members {
+		r, err := answers[i].Result, answers[i].Err
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+			continue
+		}
+		w := m.Weight
+		if w <= 0 {
+			w = 1
+		}
+		seen++
+		total += r.Confidence * w
+		wsum += w
+		if r.Confidence > maxConf {
+			maxConf = r.Confidence
+		}
+		if r.LikelyAI {
+			votes++
+		}
+		signals = append(signals, Signal{Name: m.Name, Value: r.Confidence, Weight: w})
+		sub := make([]Signal, len(r.Signals))
+		for i, s := range r.Signals {
+			s.Name = m.Name + "/" + s.Name
+			sub[i] = s
+		}
+		memberSigs = append(memberSigs, sub)
+	}
+	if seen == 0 {
+		return Result{}, fmt.Errorf("aiscan/ensemble: all members failed: %w", errors.Join(errs...))
+	}
+
+	var flat []Signal
+	for i := range signals {
+		signals[i].Contribution = signals[i].Value * signals[i].Weight / wsum
+		flat = append(flat, signals[i])
+		flat = append(flat, memberSigs[i]...)
+	}
+
+	switch e.Mode {
+	case EnsembleMax:
+		return Result{LikelyAI: maxConf >= threshold, Confidence: maxConf, Signals: flat}, nil
+	case EnsembleQuorum:
+		quorum := e.Quorum
+		if quorum <= 0 {
+			quorum = seen/2 + 1
+		}
+		return Result{LikelyAI: votes >= quorum, Confidence: float64(votes) / float64(seen), Signals: flat}, nil
+	case EnsembleWeighted, "":
+		avg := total / wsum
+		return Result{LikelyAI: avg >= threshold, Confidence: avg, Signals: flat}, nil
+	default:
+		return Result{}, fmt.Errorf("aiscan/ensemble: unknown mode %q", e.Mode)
+	}
+}
+
+// Fingerprint combines the voting settings with every member's name,
+// weight and fingerprint.
+func (e *EnsembleScanner) Fingerprint() string {
+	return e.fingerprint(Fingerprint)
+}
+
+// FingerprintPath is Fingerprint with each member's FingerprintPath.
+func (e *EnsembleScanner) FingerprintPath(path string) string {
+	return e.fingerprint(func(s Scanner) string { return FingerprintPath(s, path) })
+}
+
+func (e *EnsembleScanner) fingerprint(member func(Scanner) string) string {
+	parts := make([]string, len(e.Members))
+	for i, m := range e.Members {
+		parts[i] = fmt.Sprintf("%s:%g=%s", m.Name, m.Weight, member(m.Scanner))
+	}
+	return fmt.Sprintf("ensemble/%s/%d/%g[%s]", e.Mode, e.Quorum, e.Threshold, strings.Join(parts, ";"))
+}
+
+// PathSensitive reports whether any member is.
+func (e *EnsembleScanner) PathSensitive() bool {
+	for _, m := range e.Members {
+		if IsPathSensitive(m.Scanner) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes every member that holds resources and returns the joined
+// errors.
+func (e *EnsembleScanner) Close() error {
+	var errs []error
+	for _, m := range e.Members {
+		if err := Close(m.Scanner); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseEnsembleSpec parses a member list of the form
+//
+//	heuristic:1,http:2
+//
+// into (name, weight) pairs.  A member without ":weight" gets weight 1.
+func parseEnsembleSpec(spec string) ([]EnsembleMember, error) {
+	var members []EnsembleMember
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weightStr, hasWeight := strings.Cut(part, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if hasWeight {
+			w, err := strconv.ParseFloat(strings.TrimSpace(weightStr), 64)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight %q for ensemble member %q", weightStr, name)
+			}
+			weight = w
+		}
+		members = append(members, EnsembleMember{Name: name, Weight: weight})
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("empty ensemble member list")
+	}
+	return members, nil
+}
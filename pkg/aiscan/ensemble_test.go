@@ -0,0 +1,193 @@
+package aiscan
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// fixedScanner gives every file the same answer.
+type fixedScanner struct {
+	ai   bool
+	conf float64
+	err  error
+}
+
+func (s fixedScanner) Scan(string, []byte) (bool, float64, error) { return s.ai, s.conf, s.err }
+
+// batchingScanner answers in batches: confidence per path, an error for
+// paths in fail, or batchErr for the whole batch.
+type batchingScanner struct {
+	conf     map[string]float64
+	fail     map[string]bool
+	batchErr error
+	batches  int
+	scans    int
+}
+
+func (s *batchingScanner) Scan(path string, _ []byte) (bool, float64, error) {
+	s.scans++
+	if s.fail[path] {
+		return false, 0, errors.New("file failed")
+	}
+	return s.conf[path] >= 0.5, s.conf[path], nil
+}
+
+func (s *batchingScanner) ScanBatch(files []File) ([]BatchResult, error) {
+	s.batches++
+	if s.batchErr != nil {
+		return nil, s.batchErr
+	}
+	results := make([]BatchResult, len(files))
+	for i, f := range files {
+		if s.fail[f.Path] {
+			results[i].Err = errors.New("file failed")
+			continue
+		}
+		c := s.conf[f.Path]
+		results[i].Result = Result{LikelyAI: c >= 0.5, Confidence: c}
+	}
+	return results, nil
+}
+
+func TestEnsembleScanner(t *testing.T) {
+	ai := func(c float64) fixedScanner { return fixedScanner{ai: true, conf: c} }
+	human := func(c float64) fixedScanner { return fixedScanner{conf: c} }
+	failing := fixedScanner{err: errors.New("down")}
+	member := func(name string, s Scanner, w float64) EnsembleMember {
+		return EnsembleMember{Name: name, Scanner: s, Weight: w}
+	}
+	tests := []struct {
+		name     string
+		e        EnsembleScanner
+		likelyAI bool
+		conf     float64
+		wantErr  bool
+		answered int // members that answered, one signal each
+	}{
+		{
+			name:     "weighted",
+			e:        EnsembleScanner{Members: []EnsembleMember{member("a", ai(0.9), 3), member("b", human(0.1), 1)}},
+			likelyAI: true, conf: 0.7, answered: 2,
+		},
+		{
+			name:     "weighted tie at the threshold flags",
+			e:        EnsembleScanner{Members: []EnsembleMember{member("a", ai(0.6), 1), member("b", human(0.4), 1)}},
+			likelyAI: true, conf: 0.5, answered: 2,
+		},
+		{
+			name:     "weighted below a custom threshold",
+			e:        EnsembleScanner{Members: []EnsembleMember{member("a", ai(0.6), 1), member("b", human(0.4), 1)}, Threshold: 0.6},
+			likelyAI: false, conf: 0.5, answered: 2,
+		},
+		{
+			// Zero and negative weights count as 1, so the total is never 0.
+			name:     "weighted with zero total weight",
+			e:        EnsembleScanner{Members: []EnsembleMember{member("a", ai(0.8), 0), member("b", human(0.2), -1)}},
+			likelyAI: true, conf: 0.5, answered: 2,
+		},
+		{
+			name:     "weighted drops a failing member's weight",
+			e:        EnsembleScanner{Members: []EnsembleMember{member("a", ai(0.9), 1), member("b", failing, 10)}},
+			likelyAI: true, conf: 0.9, answered: 1,
+		},
+		{
+			name:     "max",
+			e:        EnsembleScanner{Mode: EnsembleMax, Members: []EnsembleMember{member("a", human(0.2), 5), member("b", ai(0.7), 1)}},
+			likelyAI: true, conf: 0.7, answered: 2,
+		},
+		{
+			name:     "max tie at the threshold flags",
+			e:        EnsembleScanner{Mode: EnsembleMax, Members: []EnsembleMember{member("a", human(0.5), 1), member("b", human(0.5), 1)}},
+			likelyAI: true, conf: 0.5, answered: 2,
+		},
+		{
+			name:     "max ignores a failing member",
+			e:        EnsembleScanner{Mode: EnsembleMax, Members: []EnsembleMember{member("a", failing, 1), member("b", human(0.3), 1)}},
+			likelyAI: false, conf: 0.3, answered: 1,
+		},
+		{
+			name:     "quorum tie is no majority",
+			e:        EnsembleScanner{Mode: EnsembleQuorum, Members: []EnsembleMember{member("a", ai(0.9), 1), member("b", human(0.1), 1)}},
+			likelyAI: false, conf: 0.5, answered: 2,
+		},
+		{
+			name:     "quorum majority of the members that answered",
+			e:        EnsembleScanner{Mode: EnsembleQuorum, Members: []EnsembleMember{member("a", ai(0.9), 1), member("b", failing, 1), member("c", ai(0.6), 1)}},
+			likelyAI: true, conf: 1, answered: 2,
+		},
+		{
+			name:     "explicit quorum",
+			e:        EnsembleScanner{Mode: EnsembleQuorum, Quorum: 1, Members: []EnsembleMember{member("a", ai(0.9), 1), member("b", human(0.1), 1), member("c", human(0.2), 1)}},
+			likelyAI: true, conf: 1.0 / 3, answered: 3,
+		},
+		{
+			name:    "every member fails",
+			e:       EnsembleScanner{Members: []EnsembleMember{member("a", failing, 1), member("b", failing, 1)}},
+			wantErr: true,
+		},
+		{name: "no members", e: EnsembleScanner{}, wantErr: true},
+		{name: "unknown mode", e: EnsembleScanner{Mode: "median", Members: []EnsembleMember{member("a", ai(1), 1)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.e.ScanDetailed("a.go", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if r.LikelyAI != tt.likelyAI || math.Abs(r.Confidence-tt.conf) > 1e-9 {
+				t.Errorf("got %v, %g; want %v, %g", r.LikelyAI, r.Confidence, tt.likelyAI, tt.conf)
+			}
+			if len(r.Signals) != tt.answered {
+				t.Errorf("%d signals, want one per answering member (%d): %+v", len(r.Signals), tt.answered, r.Signals)
+			}
+		})
+	}
+}
+
+func TestEnsembleScanBatch(t *testing.T) {
+	files := []File{{Path: "a.go"}, {Path: "b.go"}, {Path: "c.go"}}
+	batching := &batchingScanner{conf: map[string]float64{"a.go": 0.9, "b.go": 0.1, "c.go": 0.8}, fail: map[string]bool{"b.go": true}}
+	broken := &batchingScanner{batchErr: errors.New("endpoint down")}
+	unsupported := &batchingScanner{batchErr: ErrBatchUnsupported, conf: map[string]float64{"a.go": 0.3, "b.go": 0.3, "c.go": 0.3}}
+	e := &EnsembleScanner{Members: []EnsembleMember{
+		{Name: "batching", Scanner: batching},
+		{Name: "plain", Scanner: fixedScanner{conf: 0.1}},
+		{Name: "broken", Scanner: broken},
+		{Name: "unsupported", Scanner: unsupported},
+	}}
+	results, err := e.ScanBatch(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batching.batches != 1 || batching.scans != 0 {
+		t.Errorf("batching member: %d batches, %d scans; want one batch", batching.batches, batching.scans)
+	}
+	if unsupported.batches != 1 || unsupported.scans != len(files) {
+		t.Errorf("unsupported member: %d batches, %d scans; want a fallback to one scan per file", unsupported.batches, unsupported.scans)
+	}
+	// The broken member is left out of every file; the batching member
+	// only of b.go.
+	want := []float64{(0.9 + 0.1 + 0.3) / 3, (0.1 + 0.3) / 2, (0.8 + 0.1 + 0.3) / 3}
+	if len(results) != len(files) {
+		t.Fatalf("%d results for %d files", len(results), len(files))
+	}
+	for i, r := range results {
+		if r.Err != nil || math.Abs(r.Confidence-want[i]) > 1e-9 {
+			t.Errorf("%s: %g, %v; want %g", files[i].Path, r.Confidence, r.Err, want[i])
+		}
+	}
+
+	// A file every member fails on gets an error of its own.
+	e = &EnsembleScanner{Members: []EnsembleMember{{Name: "batching", Scanner: batching}}}
+	results, err = e.ScanBatch(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err == nil || results[2].Err != nil {
+		t.Errorf("errors = %v, %v, %v; want one for b.go only", results[0].Err, results[1].Err, results[2].Err)
+	}
+}
@@ -0,0 +1,203 @@
+package aiscan
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("exec", func(cfg execEnv, _ RepoConfig) (Scanner, error) {
+		if len(cfg.Command) == 0 {
+			return nil, fmt.Errorf("AI_SCAN_BACKEND=exec requires AI_SCAN_EXEC to be set")
+		}
+		if cfg.Timeout <= 0 {
+			return nil, fmt.Errorf("invalid AI_SCAN_EXEC_TIMEOUT %s (must be positive)", cfg.Timeout)
+		}
+		return &ExecScanner{Command: cfg.Command, Timeout: cfg.Timeout}, nil
+	})
+}
+
+// execEnv is the exec backend's config.
+type execEnv struct {
+	Command []string      `env:"AI_SCAN_EXEC" help:"detector command, split on whitespace (no shell quoting)"`
+	Timeout time.Duration `env:"AI_SCAN_EXEC_TIMEOUT" default:"30s" help:"time to wait for each response"`
+}
+
+// ExecScanner delegates scanning to a long-running local subprocess that
+// speaks a JSON-lines protocol, so detectors written in any language can be
+// plugged in without running an HTTP server.
+//
+// The command is started on the first Scan and kept running.  Each request
+// is one JSON object on its stdin, terminated by a newline:
+//
+//	{"path": "<file path>", "content": "<utf-8 text>"}
+//
+// and the subprocess must answer each request, in order, with one line on
+// stdout:
+//
+//	{"likely_ai": <bool>, "confidence": <float 0-1>}
+//
+// This is the same shape HTTPScanner uses.  A reply may instead carry
+// {"error": "<message>"} to report a per-file failure.  The subprocess's
+// stderr is passed through to ours.  If the subprocess exits, writes
+// malformed output or takes longer than Timeout to answer, it is killed and
+// that Scan and every later one fail.
+type ExecScanner struct {
+	// Command is the program and its arguments (from AI_SCAN_EXEC).
+	Command []string
+	// Timeout bounds each request and its response (from
+	// AI_SCAN_EXEC_TIMEOUT).  Zero means 30s.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	err    error // sticky failure; once set the subprocess is unusable
+}
+
+type execResponse struct {
+	httpResponse
+	Error string `json:"error,omitempty"`
+}
+
+// Fingerprint identifies the detector by its command line.
+func (s *ExecScanner) Fingerprint() string { return "exec/" + strings.Join(s.Command, " ") }
+
+// PathSensitive reports true: the detector receives each file's path.
+func (s *ExecScanner) PathSensitive() bool { return true }
+
+func (s *ExecScanner) Scan(path string, content []byte) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.start(); err != nil {
+		return false, 0, err
+	}
+
+	line, err := json.Marshal(httpRequest{Path: path, Content: string(content)})
+	if err != nil {
+		return false, 0, fmt.Errorf("aiscan/exec: marshal request: %w", err)
+	}
+	reply, err := s.roundTrip(append(line, '\n'))
+	if err != nil {
+		return false, 0, s.fail(err)
+	}
+	var result execResponse
+	if err := json.Unmarshal(reply, &result); err != nil {
+		return false, 0, s.fail(fmt.Errorf("decode response %q: %w", reply, err))
+	}
+	if result.Error != "" {
+		return false, 0, fmt.Errorf("aiscan/exec: %s: %s", path, result.Error)
+	}
+	return result.LikelyAI, result.Confidence, nil
+}
+
+const defaultExecTimeout = 30 * time.Second
+
+// roundTrip writes one request line and reads the reply line, giving up
+// after Timeout.  On a timeout the caller's fail kills the subprocess,
+// which unblocks the goroutine still waiting on its pipes.
+func (s *ExecScanner) roundTrip(line []byte) ([]byte, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+	type reply struct {
+		line []byte
+		err  error
+	}
+	done := make(chan reply, 1)
+	stdin, stdout := s.stdin, s.stdout
+	go func() {
+		if _, err := stdin.Write(line); err != nil {
+			done <- reply{err: fmt.Errorf("write request: %w", err)}
+			return
+		}
+		b, err := stdout.ReadBytes('\n')
+		if err != nil {
+			err = fmt.Errorf("read response: %w", err)
+		}
+		done <- reply{b, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("no response within %s", timeout)
+	}
+}
+
+// Close closes the subprocess's stdin and waits for it to exit.  It is safe
+// to call on a scanner that was never used.
+func (s *ExecScanner) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil {
+		return nil
+	}
+	s.stdin.Close()
+	err := s.cmd.Wait()
+	s.cmd = nil
+	if s.err == nil {
+		s.err = fmt.Errorf("aiscan/exec: scanner closed")
+	}
+	return err
+}
+
+// start launches the subprocess if it is not already running.
+func (s *ExecScanner) start() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.cmd != nil {
+		return nil
+	}
+	if len(s.Command) == 0 {
+		s.err = fmt.Errorf("aiscan/exec: no command configured")
+		return s.err
+	}
+
+	cmd := exec.Command(s.Command[0], s.Command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return s.fail(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return s.fail(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return s.fail(fmt.Errorf("start %s: %w", s.Command[0], err))
+	}
+	s.cmd, s.stdin, s.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// fail records err as the scanner's sticky failure, stops the subprocess,
+// and returns the wrapped error.  If the subprocess had already exited with
+// a status, the error says so.
+func (s *ExecScanner) fail(err error) error {
+	if s.cmd != nil {
+		s.stdin.Close()
+		_ = s.cmd.Process.Kill()
+		var ee *exec.ExitError
+		if errors.As(s.cmd.Wait(), &ee) && ee.Exited() {
+			err = fmt.Errorf("%w (%s %s)", err, s.Command[0], ee)
+		}
+		s.cmd = nil
+	}
+	s.err = fmt.Errorf("aiscan/exec: %w", err)
+	return s.err
+}
@@ -0,0 +1,129 @@
+package aiscan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain doubles as the detector the exec tests run: with
+// AISCAN_EXEC_STUB set, the test binary behaves as the stub it names
+// instead of running tests.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("AISCAN_EXEC_STUB"); mode != "" {
+		execStub(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// execStub answers exec-backend requests on stdin the way mode says.
+func execStub(mode string) {
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(nil, 1<<20)
+	for in.Scan() {
+		var req httpRequest
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			fmt.Fprintf(os.Stderr, "stub: %v\n", err)
+			os.Exit(2)
+		}
+		switch mode {
+		case "ok":
+			ai := strings.Contains(req.Content, "delve")
+			conf := 0.1
+			if ai {
+				conf = 0.9
+			}
+			fmt.Printf(`{"likely_ai": %v, "confidence": %v}`+"\n", ai, conf)
+		case "file-error":
+			fmt.Printf(`{"error": "cannot read %s"}`+"\n", req.Path)
+		case "garbage":
+			fmt.Println("not json")
+		case "exit":
+			os.Exit(3)
+		case "hang":
+			time.Sleep(time.Hour)
+		}
+	}
+}
+
+func stubScanner(t *testing.T, mode string) *ExecScanner {
+	t.Helper()
+	t.Setenv("AISCAN_EXEC_STUB", mode)
+	s := &ExecScanner{Command: []string{os.Args[0]}, Timeout: 5 * time.Second}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestExecScanner(t *testing.T) {
+	s := stubScanner(t, "ok")
+	for _, tc := range []struct {
+		content string
+		ai      bool
+	}{
+		{"let us delve into it", true},
+		{"plain text", false},
+		{"delve again on the same process", true},
+	} {
+		ai, conf, err := s.Scan("a.md", []byte(tc.content))
+		if err != nil {
+			t.Fatalf("Scan(%q): %v", tc.content, err)
+		}
+		if ai != tc.ai || (conf > 0.5) != tc.ai {
+			t.Errorf("Scan(%q) = %v, %v; want likely AI %v", tc.content, ai, conf, tc.ai)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, _, err := s.Scan("a.md", nil); err == nil {
+		t.Errorf("Scan after Close succeeded")
+	}
+}
+
+func TestExecScannerFileError(t *testing.T) {
+	s := stubScanner(t, "file-error")
+	for i := 0; i < 2; i++ {
+		// A per-file error leaves the subprocess usable.
+		_, _, err := s.Scan("x.go", nil)
+		if err == nil || !strings.Contains(err.Error(), "cannot read x.go") {
+			t.Fatalf("Scan %d error = %v, want the reported error", i, err)
+		}
+	}
+}
+
+func TestExecScannerFailures(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"garbage", "decode response"},
+		{"exit", "exit status 3"},
+		{"hang", "no response within"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			s := stubScanner(t, tt.mode)
+			s.Timeout = 200 * time.Millisecond
+			_, _, err := s.Scan("a.md", []byte("text"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Scan error = %v, want %q", err, tt.want)
+			}
+			// The failure is sticky.
+			if _, _, again := s.Scan("b.md", nil); again == nil || again.Error() != err.Error() {
+				t.Errorf("second Scan error = %v, want %v", again, err)
+			}
+		})
+	}
+}
+
+func TestExecScannerMissingCommand(t *testing.T) {
+	s := &ExecScanner{Command: []string{"/nonexistent/detector"}}
+	if _, _, err := s.Scan("a.md", nil); err == nil || !strings.Contains(err.Error(), "start /nonexistent/detector") {
+		t.Errorf("Scan error = %v, want a start failure", err)
+	}
+}
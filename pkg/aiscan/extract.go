@@ -0,0 +1,580 @@
+package aiscan
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Language identifies the syntax used to separate prose from code.
+type Language string
+
+const (
+	LangUnknown  Language = ""
+	LangGo       Language = "go"
+	LangRust     Language = "rust"
+	LangJS       Language = "js"
+	LangPython   Language = "python"
+	LangShell    Language = "shell"
+	LangConfig   Language = "config" // YAML / TOML
+	LangMarkdown Language = "markdown"
+)
+
+// IsCode reports whether l is a programming language whose files are mostly
+// code, as opposed to prose (Markdown) or unknown text.
+func (l Language) IsCode() bool {
+	switch l {
+	case LangGo, LangRust, LangJS, LangPython, LangShell:
+		return true
+	}
+	return false
+}
+
+// DetectLanguage picks a Language from the file extension, falling back to
+// the shebang line for extensionless scripts.
+func DetectLanguage(path string, content []byte) Language {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go":
+		return LangGo
+	case ".rs":
+		return LangRust
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".mts", ".cts", ".tsx":
+		return LangJS
+	case ".py":
+		return LangPython
+	case ".sh", ".bash", ".zsh":
+		return LangShell
+	case ".yaml", ".yml", ".toml":
+		return LangConfig
+	case ".md", ".markdown":
+		return LangMarkdown
+	case "":
+		if bytes.HasPrefix(content, []byte("#!")) {
+			first, _, _ := bytes.Cut(content, []byte("\n"))
+			if bytes.Contains(first, []byte("python")) {
+				return LangPython
+			}
+			return LangShell
+		}
+	}
+	return LangUnknown
+}
+
+// Comment is one comment (or one line of a run of line comments) found by
+// Extract, with its markers stripped.
+type Comment struct {
+	Line int // 1-based
+	Text string
+	// OwnLine is true when nothing but whitespace precedes the comment on
+	// its line.
+	OwnLine bool
+}
+
+// Extraction is the prose view of a source file produced by Extract.
+type Extraction struct {
+	Language Language
+	// Prose has the same length and line layout as the source, with every
+	// byte that is not comment, doc comment, prose-like string literal or
+	// Markdown prose replaced by a space.  Line numbers in Prose therefore
+	// match the original file.
+	Prose string
+	// Comments lists the comments in source order (code languages only).
+	Comments []Comment
+	// Blocks lists the blocks of a Markdown document (see ParseMarkdown).
+	Blocks []Block
+	// CommentLines and CodeLines count non-blank lines holding comment
+	// text and code respectively; a line can count towards both.
+	CommentLines, CodeLines int
+	// codeText maps 1-based line numbers to the code on that line.
+	codeText map[int]string
+}
+
+// proseStringWords is the minimum number of words a string literal needs to
+// be treated as prose (user-facing messages) rather than an identifier-like
+// value.
+const proseStringWords = 5
+
+type syntax struct {
+	lineComments  []string
+	blockComments [][2]string
+	// quotes lists string delimiters, longest first.  Backslash escapes
+	// apply to all except rawQuotes.
+	quotes    []string
+	rawQuotes []string
+	// multiline lists delimiters whose strings may span lines.
+	multiline []string
+	// hashNeedsSpace means "#" only opens a comment at the start of a line
+	// or after whitespace (shell "$#", YAML "a#b").
+	hashNeedsSpace bool
+	// rawHashStrings enables Rust raw strings: r"…", r#"…"#, br##"…"##.
+	rawHashStrings bool
+	// charLiterals enables Rust char and byte literals ('x', '\'', '"',
+	// '\u{1F600}'), told apart from lifetimes ('a, 'static).
+	charLiterals bool
+	// heredocs enables shell here-documents (<<EOF … EOF), whose bodies are
+	// treated as string literals.
+	heredocs bool
+}
+
+var syntaxes = map[Language]syntax{
+	LangGo: {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []string{`"`, `'`},
+		rawQuotes:     []string{"`"},
+		multiline:     []string{"`"},
+	},
+	LangRust: {
+		lineComments:   []string{"//"},
+		blockComments:  [][2]string{{"/*", "*/"}},
+		quotes:         []string{`"`},
+		multiline:      []string{`"`},
+		rawHashStrings: true,
+		charLiterals:   true,
+	},
+	LangJS: {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []string{"`", `"`, `'`},
+		multiline:     []string{"`"},
+	},
+	LangPython: {
+		lineComments: []string{"#"},
+		quotes:       []string{`"""`, `'''`, `"`, `'`},
+		multiline:    []string{`"""`, `'''`},
+	},
+	LangShell: {
+		lineComments:   []string{"#"},
+		quotes:         []string{`"`},
+		rawQuotes:      []string{`'`},
+		multiline:      []string{`"`, `'`},
+		hashNeedsSpace: true,
+		heredocs:       true,
+	},
+	LangConfig: {
+		lineComments:   []string{"#"},
+		quotes:         []string{`"""`, `"`},
+		rawQuotes:      []string{`'''`, `'`},
+		multiline:      []string{`"""`, `'''`},
+		hashNeedsSpace: true,
+	},
+}
+
+// Extract separates the prose in content from its code.  For Markdown the
+// prose is the paragraphs and list items (headings, tables and fenced code
+// are blanked); for unknown languages the whole file is prose.
+func Extract(path string, content []byte) Extraction {
+	lang := DetectLanguage(path, content)
+	switch lang {
+	case LangMarkdown:
+		blocks := ParseMarkdown(content)
+		return Extraction{Language: lang, Prose: markdownProse(content, blocks), Blocks: blocks}
+	case LangUnknown:
+		return Extraction{Language: lang, Prose: string(content)}
+	}
+	return extractCode(lang, syntaxes[lang], content)
+}
+
+func blankLine(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return strings.Repeat(" ", len(line)-1) + "\n"
+	}
+	return strings.Repeat(" ", len(line))
+}
+
+func extractCode(lang Language, syn syntax, src []byte) Extraction {
+	out := make([]byte, len(src))
+	for i, b := range src {
+		if b == '\n' {
+			out[i] = '\n'
+		} else {
+			out[i] = ' '
+		}
+	}
+
+	ex := Extraction{Language: lang, codeText: map[int]string{}}
+	commentLine := map[int]bool{}
+	codeLine := map[int]bool{}
+	var code strings.Builder // code bytes on the current line
+
+	line := 1
+	lineStart := true // only whitespace seen on this line so far
+	endLine := func() {
+		if s := strings.TrimSpace(code.String()); s != "" {
+			codeLine[line] = true
+			ex.codeText[line] = s
+		}
+		code.Reset()
+		line++
+		lineStart = true
+	}
+
+	// copyProse copies src[from:to] into the prose view, tracking line
+	// breaks, and returns the comment text with per-line "*" gutters removed.
+	copyProse := func(from, to int, block bool) string {
+		var text strings.Builder
+		gutter := false
+		for j := from; j < to; j++ {
+			b := src[j]
+			if b == '\n' {
+				text.WriteByte('\n')
+				endLine()
+				gutter = block
+				continue
+			}
+			if gutter {
+				if b == ' ' || b == '\t' {
+					continue
+				}
+				gutter = false
+				if b == '*' {
+					continue
+				}
+			}
+			out[j] = b
+			text.WriteByte(b)
+			if b != ' ' && b != '\t' && b != '\r' {
+				commentLine[line] = true
+			}
+		}
+		return text.String()
+	}
+
+	// literal accounts for the body of a string literal, src[from:to]: it
+	// is code, and also prose when it reads like a sentence.
+	literal := func(from, to int) {
+		body := src[from:to]
+		prose := len(strings.Fields(string(body))) >= proseStringWords
+		for k := from; k < to; k++ {
+			if src[k] == '\n' {
+				endLine()
+				continue
+			}
+			code.WriteByte(src[k])
+			if prose {
+				out[k] = src[k]
+			}
+		}
+	}
+
+	// heredocs pending on the current line; their bodies start on the next.
+	var heredocs []heredoc
+
+	i := 0
+	for i < len(src) {
+		b := src[i]
+		if b == '\n' {
+			endLine()
+			i++
+			for _, h := range heredocs {
+				bodyEnd, next := h.body(src, i)
+				literal(i, bodyEnd)
+				code.Write(bytes.TrimRight(src[bodyEnd:next], "\n"))
+				if next > bodyEnd && src[next-1] == '\n' {
+					endLine()
+				}
+				i = next
+			}
+			heredocs = nil
+			continue
+		}
+
+		if syn.heredocs {
+			if h, n, ok := matchHeredoc(src, i); ok {
+				heredocs = append(heredocs, h)
+				code.Write(src[i : i+n])
+				lineStart = false
+				i += n
+				continue
+			}
+		}
+
+		if open, close, ok := matchBlock(syn, src, i); ok {
+			startLine, ownLine := line, lineStart
+			end := bytes.Index(src[i+len(open):], []byte(close))
+			bodyEnd, next := len(src), len(src)
+			if end >= 0 {
+				bodyEnd = i + len(open) + end
+				next = bodyEnd + len(close)
+			}
+			text := copyProse(i+len(open), bodyEnd, true)
+			ex.Comments = append(ex.Comments, Comment{Line: startLine, Text: strings.TrimSpace(text), OwnLine: ownLine})
+			lineStart = false
+			i = next
+			continue
+		}
+
+		if open, ok := matchLineComment(syn, src, i, lineStart); ok {
+			startLine, ownLine := line, lineStart
+			end := bytes.IndexByte(src[i:], '\n')
+			stop := len(src)
+			if end >= 0 {
+				stop = i + end
+			}
+			from := i + len(open)
+			// Doc comments ("///", "//!", "##") keep their extra marker out
+			// of the prose.
+			for from < stop && (src[from] == '/' || src[from] == '!' || src[from] == '#') {
+				from++
+			}
+			text := copyProse(from, stop, false)
+			ex.Comments = append(ex.Comments, Comment{Line: startLine, Text: strings.TrimSpace(text), OwnLine: ownLine})
+			lineStart = false
+			i = stop
+			continue
+		}
+
+		if syn.rawHashStrings {
+			if open, close, ok := matchRawHashString(src, i); ok {
+				end := bytes.Index(src[i+len(open):], []byte(close))
+				bodyEnd, next := len(src), len(src)
+				if end >= 0 {
+					bodyEnd = i + len(open) + end
+					next = bodyEnd + len(close)
+				}
+				code.WriteString(open)
+				literal(i+len(open), bodyEnd)
+				code.Write(src[bodyEnd:next])
+				lineStart = false
+				i = next
+				continue
+			}
+		}
+
+		if syn.charLiterals {
+			if n, ok := matchCharLiteral(src, i); ok {
+				code.Write(src[i : i+n])
+				lineStart = false
+				i += n
+				continue
+			}
+		}
+
+		if q, raw, ok := matchQuote(syn, src, i); ok {
+			end, closed := quoteEnd(src, i+len(q), q, raw, contains(syn.multiline, q))
+			if !closed {
+				// An unterminated quote is more likely a stray apostrophe
+				// than a string running to EOF: end it with its line.
+				if nl := bytes.IndexByte(src[i:], '\n'); nl >= 0 && nl < end-i {
+					end = i + nl
+				}
+			}
+			code.WriteString(q)
+			literal(i+len(q), end)
+			lineStart = false
+			i = end
+			if closed {
+				code.WriteString(q)
+				i += len(q)
+			}
+			continue
+		}
+
+		if b != ' ' && b != '\t' && b != '\r' {
+			lineStart = false
+		}
+		code.WriteByte(b)
+		i++
+	}
+	endLine()
+
+	ex.Prose = string(out)
+	ex.CommentLines = len(commentLine)
+	ex.CodeLines = len(codeLine)
+	return ex
+}
+
+// quoteEnd returns the offset of the quote q closing the string whose body
+// starts at from, and whether it was found.  A single-line string ends
+// unclosed at the end of its line; any string ends unclosed at EOF.
+func quoteEnd(src []byte, from int, q string, raw, multi bool) (int, bool) {
+	j := from
+	for j < len(src) {
+		if !raw && src[j] == '\\' {
+			j += 2
+			continue
+		}
+		if src[j] == '\n' && !multi {
+			return j, false
+		}
+		if bytes.HasPrefix(src[j:], []byte(q)) {
+			return j, true
+		}
+		j++
+	}
+	return len(src), false
+}
+
+// matchRawHashString matches a Rust raw string opener at i ("r\"", "r#\"",
+// "br##\"", …) and returns it with its closing delimiter.
+func matchRawHashString(src []byte, i int) (string, string, bool) {
+	if i > 0 && isIdentByte(src[i-1]) {
+		return "", "", false
+	}
+	j := i
+	if j < len(src) && src[j] == 'b' {
+		j++
+	}
+	if j >= len(src) || src[j] != 'r' {
+		return "", "", false
+	}
+	j++
+	hashes := 0
+	for j < len(src) && src[j] == '#' {
+		hashes++
+		j++
+	}
+	if j >= len(src) || src[j] != '"' {
+		return "", "", false
+	}
+	return string(src[i : j+1]), `"` + strings.Repeat("#", hashes), true
+}
+
+// maxCharLiteral is the length of the longest Rust char literal,
+// '\u{10FFFF}'.
+const maxCharLiteral = 12
+
+// matchCharLiteral matches a Rust char literal at i and returns its
+// length.  A quote followed by one character and no closing quote is a
+// lifetime or loop label, not a literal.
+func matchCharLiteral(src []byte, i int) (int, bool) {
+	if src[i] != '\'' || i+2 >= len(src) {
+		return 0, false
+	}
+	j := i + 1
+	if src[j] == '\\' {
+		for k := j + 2; k < len(src) && k-i < maxCharLiteral; k++ {
+			if src[k] == '\n' {
+				break
+			}
+			if src[k] == '\'' {
+				return k + 1 - i, true
+			}
+		}
+		return 0, false
+	}
+	if src[j] == '\n' || src[j] == '\'' {
+		return 0, false
+	}
+	_, size := utf8.DecodeRune(src[j:])
+	if j+size < len(src) && src[j+size] == '\'' {
+		return j + size + 1 - i, true
+	}
+	return 0, false
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// heredoc is a pending shell here-document.
+type heredoc struct {
+	delim     string
+	stripTabs bool // <<- strips leading tabs from the body and terminator
+}
+
+// matchHeredoc matches a here-document redirection at i ("<<EOF",
+// "<<-'EOF'", "<< \"END\"") and returns it with its length.  Here-strings
+// ("<<<") are not here-documents.
+func matchHeredoc(src []byte, i int) (heredoc, int, bool) {
+	if !bytes.HasPrefix(src[i:], []byte("<<")) || bytes.HasPrefix(src[i:], []byte("<<<")) {
+		return heredoc{}, 0, false
+	}
+	j := i + 2
+	var h heredoc
+	if j < len(src) && src[j] == '-' {
+		h.stripTabs = true
+		j++
+	}
+	for j < len(src) && (src[j] == ' ' || src[j] == '\t') {
+		j++
+	}
+	var quote byte
+	if j < len(src) && (src[j] == '\'' || src[j] == '"') {
+		quote = src[j]
+		j++
+	}
+	start := j
+	for j < len(src) && isIdentByte(src[j]) {
+		j++
+	}
+	if j == start {
+		return heredoc{}, 0, false
+	}
+	h.delim = string(src[start:j])
+	if quote != 0 {
+		if j >= len(src) || src[j] != quote {
+			return heredoc{}, 0, false
+		}
+		j++
+	}
+	return h, j - i, true
+}
+
+// body returns where the here-document starting at from ends: the start
+// of its terminator line, and the offset just past that line (EOF when the
+// terminator is missing).
+func (h heredoc) body(src []byte, from int) (int, int) {
+	for j := from; j < len(src); {
+		end := bytes.IndexByte(src[j:], '\n')
+		next := len(src)
+		if end >= 0 {
+			next = j + end + 1
+		}
+		line := strings.TrimRight(string(src[j:next]), "\r\n")
+		if h.stripTabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == h.delim {
+			return j, next
+		}
+		j = next
+	}
+	return len(src), len(src)
+}
+
+func matchBlock(syn syntax, src []byte, i int) (string, string, bool) {
+	for _, bc := range syn.blockComments {
+		if bytes.HasPrefix(src[i:], []byte(bc[0])) {
+			return bc[0], bc[1], true
+		}
+	}
+	return "", "", false
+}
+
+func matchLineComment(syn syntax, src []byte, i int, lineStart bool) (string, bool) {
+	for _, lc := range syn.lineComments {
+		if !bytes.HasPrefix(src[i:], []byte(lc)) {
+			continue
+		}
+		if lc == "#" && syn.hashNeedsSpace && !lineStart && i > 0 && src[i-1] != ' ' && src[i-1] != '\t' {
+			continue
+		}
+		return lc, true
+	}
+	return "", false
+}
+
+func matchQuote(syn syntax, src []byte, i int) (string, bool, bool) {
+	for _, q := range syn.quotes {
+		if bytes.HasPrefix(src[i:], []byte(q)) {
+			return q, false, true
+		}
+	}
+	for _, q := range syn.rawQuotes {
+		if bytes.HasPrefix(src[i:], []byte(q)) {
+			return q, true, true
+		}
+	}
+	return "", false, false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package aiscan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		src       string
+		comments  []string
+		codeLines int
+		prose     []string // must appear in Prose
+		notProse  []string // must not appear in Prose
+	}{
+		{
+			name: "go",
+			path: "x.go",
+			src: "package x\n\n// Add sums two numbers.\nfunc Add(a, b int) int { return a + b } /* trailing */\n" +
+				"var s = `raw\nstring`\n",
+			comments:  []string{"Add sums two numbers.", "trailing"},
+			codeLines: 4,
+			prose:     []string{"Add sums two numbers."},
+			notProse:  []string{"func", "raw"},
+		},
+		{
+			name:      "go prose string",
+			path:      "x.go",
+			src:       "package x\n\nvar msg = \"could not open the file you asked for\"\n",
+			codeLines: 2,
+			prose:     []string{"could not open the file you asked for"},
+		},
+		{
+			name: "rust raw strings",
+			path: "x.rs",
+			src: "fn main() {\n    let s = r#\"a \"quoted\" // not a comment\"#;\n" +
+				"    let t = br\"bytes\";\n    let u = r##\"x\n\"# still inside\n\"##;\n    // real comment\n}\n",
+			comments:  []string{"real comment"},
+			codeLines: 7,
+		},
+		{
+			name:      "rust identifier ending in r",
+			path:      "x.rs",
+			src:       "fn f() { let bar = 1; let x = \"s\"; } // done\n",
+			comments:  []string{"done"},
+			codeLines: 1,
+		},
+		{
+			name: "rust char literals and lifetimes",
+			path: "x.rs",
+			src: "fn quote<'a>(s: &'a str) -> bool { s.starts_with('\"') }\n// after a quote char\n" +
+				"const Q: [char; 4] = ['\\'', '\\u{1F600}', 'é', b'\"' as char]; // escapes\n" +
+				"fn f(x: &'static str) {} // static\n'outer: loop { break 'outer; } // label\n",
+			comments:  []string{"after a quote char", "escapes", "static", "label"},
+			codeLines: 4,
+			prose:     []string{"after a quote char"},
+		},
+		{
+			name:      "js template literal",
+			path:      "x.ts",
+			src:       "const a = `line one\nline two`; // note\nconst b = 'it''s';\n",
+			comments:  []string{"note"},
+			codeLines: 3,
+		},
+		{
+			name:      "python docstring",
+			path:      "x.py",
+			src:       "def f():\n    \"\"\"Return the answer to the question that was asked.\"\"\"\n    return 42  # the answer\n",
+			comments:  []string{"the answer"},
+			codeLines: 3,
+			prose:     []string{"Return the answer to the question that was asked."},
+		},
+		{
+			name:      "shell stray apostrophe",
+			path:      "x.sh",
+			src:       "#!/bin/sh\necho don't\n# second comment\necho ok\n",
+			comments:  []string{"bin/sh", "second comment"},
+			codeLines: 2,
+			prose:     []string{"second comment"},
+		},
+		{
+			name:      "shell heredoc",
+			path:      "x.sh",
+			src:       "cat <<-'EOF'\n\tit's a heredoc # not a comment\n\tEOF\n# after\necho $# done\n",
+			comments:  []string{"after"},
+			codeLines: 4,
+			prose:     []string{"after"},
+		},
+		{
+			name:      "shell here-string",
+			path:      "x.sh",
+			src:       "cat <<< word # comment\n",
+			comments:  []string{"comment"},
+			codeLines: 1,
+		},
+		{
+			name:      "yaml",
+			path:      "x.yaml",
+			src:       "# top\nkey: 'value' # why\nurl: a#b\n",
+			comments:  []string{"top", "why"},
+			codeLines: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ex := Extract(tt.path, []byte(tt.src))
+			var got []string
+			for _, c := range ex.Comments {
+				got = append(got, c.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.comments, "|") {
+				t.Errorf("comments = %q, want %q", got, tt.comments)
+			}
+			if ex.CodeLines != tt.codeLines {
+				t.Errorf("CodeLines = %d, want %d (code: %v)", ex.CodeLines, tt.codeLines, ex.codeText)
+			}
+			if len(ex.Prose) != len(tt.src) || strings.Count(ex.Prose, "\n") != strings.Count(tt.src, "\n") {
+				t.Errorf("Prose layout differs from source")
+			}
+			for _, p := range tt.prose {
+				if !strings.Contains(ex.Prose, p) {
+					t.Errorf("Prose missing %q:\n%s", p, ex.Prose)
+				}
+			}
+			for _, p := range tt.notProse {
+				if strings.Contains(ex.Prose, p) {
+					t.Errorf("Prose contains %q:\n%s", p, ex.Prose)
+				}
+			}
+		})
+	}
+}
+
+// Code after a multi-line string must be attributed to the right lines.
+func TestExtractLineAccounting(t *testing.T) {
+	src := "package x\n\nvar s = `a\nb\nc`\n\nvar t = 1\n"
+	ex := Extract("x.go", []byte(src))
+	for line, want := range map[int]string{3: "var s = `a", 4: "b", 5: "c`", 7: "var t = 1"} {
+		if got := ex.codeText[line]; got != want {
+			t.Errorf("line %d code = %q, want %q", line, got, want)
+		}
+	}
+	if _, ok := ex.codeText[6]; ok {
+		t.Errorf("blank line 6 counted as code")
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	src := "# Title\n\nSome prose here.\n\n```go\ncode()\n```\n"
+	ex := Extract("README.md", []byte(src))
+	if ex.Language != LangMarkdown {
+		t.Fatalf("Language = %q", ex.Language)
+	}
+	if !strings.Contains(ex.Prose, "Some prose here.") || strings.Contains(ex.Prose, "code()") || strings.Contains(ex.Prose, "Title") {
+		t.Errorf("Prose = %q", ex.Prose)
+	}
+}
@@ -2,11 +2,38 @@
 package aiscan
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"unicode"
 )
 
+func init() {
+	Register("heuristic", newHeuristicScanner)
+}
+
+// heuristicEnv is the heuristic backend's config.
+type heuristicEnv struct {
+	ConfigFile string `env:"AI_SCAN_CONFIG" help:"JSON or YAML HeuristicConfig file; overrides the repo's ai-scan.heuristic"`
+}
+
+func newHeuristicScanner(cfg heuristicEnv, rc RepoConfig) (Scanner, error) {
+	hc := rc.Heuristic
+	if cfg.ConfigFile != "" {
+		var err error
+		if hc, err = LoadHeuristicConfig(cfg.ConfigFile); err != nil {
+			return nil, fmt.Errorf("AI_SCAN_CONFIG: %w", err)
+		}
+	} else if err := hc.Validate(); err != nil {
+		return nil, fmt.Errorf(".portal-config.yaml ai-scan.heuristic: %w", err)
+	}
+	return &HeuristicScanner{Config: hc, LoadMarkdown: rc.LoadMarkdown}, nil
+}
+
 // HeuristicScanner uses lightweight statistical signals to flag content that
 // exhibits patterns common in LLM output. It requires no external dependencies
 // or network access.
@@ -14,159 +41,276 @@ import (
 // Signals used (each contributes a weighted score; threshold is 0.5):
 //
 //  1. Phrase density  — frequency of phrases that LLMs overuse
-//  2. Type-token ratio (TTR) — LLM output tends toward moderate TTR with
-//     very uniform sentence lengths; extremely high or low values in
-//     combination with other signals are suspicious
-//  3. Bullet/header density — LLMs love structured lists and markdown headers
+//  2. Lexical uniformity — LLM output tends toward a moderate type-token
+//     ratio, very uniform sentence lengths and long words; scored as three
+//     separate signals (moving-average TTR, sentence-length "burstiness",
+//     average word length), each only once there are 100+ words of prose
+//  3. Bullet/header density — LLMs love structured lists and markdown headers;
+//     for Markdown files this is replaced by "markdown-structure", which
+//     compares the document's shape (heading and list share, bold list
+//     lead-ins, words per section) with the repo's own docs (Markdown)
 //  4. Hedge phrase density — "it's worth noting", "it is important to",
 //     "as an AI language model", etc.
-type HeuristicScanner struct{}
+//
+// For recognised languages (see DetectLanguage) only the prose regions —
+// comments, doc comments, prose-like string literals, Markdown paragraphs
+// and list items — are scored, so identifiers and punctuation don't count as
+// sentences.  Code files additionally get two code-style signals:
+//
+//  5. Comment ratio — share of lines that are comments
+//  6. Restated comments — own-line comments that repeat the identifiers of
+//     the code line below them
+//
+// The lexicons, weights, squash curve and threshold can be tuned per repo
+// through Config (see HeuristicConfig); the zero value uses the built-in
+// defaults.
+type HeuristicScanner struct {
+	Config HeuristicConfig
+	// Markdown is the profile of the repo's existing docs that
+	// markdown-structure compares against; nil uses LoadMarkdown, or a
+	// built-in profile.
+	Markdown *MarkdownProfile
+	// LoadMarkdown, when set and Markdown is nil, is called once, on the
+	// first Markdown file scored, to build Markdown.
+	LoadMarkdown func() (*MarkdownProfile, error)
 
-func (h *HeuristicScanner) Scan(_ string, content []byte) (bool, float64, error) {
-	text := string(content)
-	score := h.score(text)
-	const threshold = 0.5
-	return score >= threshold, score, nil
+	markdownOnce sync.Once
+	markdownErr  error
 }
 
-// score returns a value in [0, 1]; >= 0.5 means likely AI.
-func (h *HeuristicScanner) score(text string) float64 {
-	if strings.TrimSpace(text) == "" {
-		return 0
+func (h *HeuristicScanner) Scan(path string, content []byte) (bool, float64, error) {
+	r, err := h.ScanDetailed(path, content)
+	return r.LikelyAI, r.Confidence, err
+}
+
+// ScanDetailed is like Scan but also reports every signal with its weighted
+// contribution and, for phrase-based signals, the matching lines.
+func (h *HeuristicScanner) ScanDetailed(path string, content []byte) (Result, error) {
+	ex := Extract(path, content)
+	if ex.Language == LangMarkdown {
+		if err := h.loadMarkdown(); err != nil {
+			return Result{}, err
+		}
 	}
+	score, signals := h.score(ex)
+	return Result{LikelyAI: score >= h.Config.threshold(), Confidence: score, Signals: signals}, nil
+}
 
-	weights := []struct {
-		v float64
-		w float64
-	}{
-		{h.phraseDensity(text), 0.45},
-		{h.hedgeDensity(text), 0.35},
-		{h.bulletHeaderDensity(text), 0.20},
+// loadMarkdown fills in Markdown from LoadMarkdown the first time it is
+// needed.  A failure is remembered and returned for every Markdown file.
+func (h *HeuristicScanner) loadMarkdown() error {
+	h.markdownOnce.Do(func() {
+		if h.Markdown != nil || h.LoadMarkdown == nil {
+			return
+		}
+		p, err := h.LoadMarkdown()
+		if err != nil {
+			h.markdownErr = fmt.Errorf("aiscan/heuristic: markdown baseline: %w", err)
+			return
+		}
+		h.Markdown = p
+	})
+	return h.markdownErr
+}
+
+// heuristicVersion identifies the scoring code for cache fingerprints.
+// Bump it whenever a change to the signals could change a score.
+const heuristicVersion = 2
+
+// Fingerprint covers the scoring code version and the effective Config.
+// The Markdown profile only affects Markdown files, so it is left to
+// FingerprintPath; editing the repo's docs then does not invalidate cached
+// results for code.
+func (h *HeuristicScanner) Fingerprint() string {
+	cfg, _ := json.Marshal(h.Config)
+	sum := sha256.Sum256(cfg)
+	return fmt.Sprintf("heuristic/v%d/%s", heuristicVersion, hex.EncodeToString(sum[:8]))
+}
+
+// FingerprintPath is Fingerprint, plus the Markdown profile (loaded if
+// need be) for a Markdown path.
+func (h *HeuristicScanner) FingerprintPath(path string) string {
+	if DetectLanguage(path, nil) != LangMarkdown {
+		return h.Fingerprint()
+	}
+	if err := h.loadMarkdown(); err != nil {
+		return h.Fingerprint() + "/md:error" // ScanDetailed fails too, so nothing is cached
+	}
+	profile := "builtin"
+	if h.Markdown != nil {
+		data, _ := json.Marshal(h.Markdown)
+		sum := sha256.Sum256(data)
+		profile = hex.EncodeToString(sum[:8])
+	}
+	return h.Fingerprint() + "/md:" + profile
+}
+
+// score returns a value in [0, 1]; at or above the threshold (0.5 by
+// default) means likely AI.  The returned
+// signals carry the per-signal evidence behind the score.
+func (h *HeuristicScanner) score(ex Extraction) (float64, []Signal) {
+	text := ex.Prose
+	if strings.TrimSpace(text) == "" && !ex.Language.IsCode() {
+		return 0, nil
+	}
+
+	phrase, phraseHits := h.phraseDensity(text)
+	hedge, hedgeHits := h.hedgeDensity(text)
+	uniformity := burstiness(text)
+	structure := Signal{Name: "bullet-header-density", Value: h.bulletHeaderDensity(text)}
+	if ex.Language == LangMarkdown {
+		structure = Signal{Name: "markdown-structure", Value: markdownStructure(markdownStats(ex.Blocks), h.Markdown)}
+	}
+	signals := []Signal{
+		{Name: "phrase-density", Value: phrase, Matches: phraseHits},
+		{Name: "hedge-density", Value: hedge, Matches: hedgeHits},
+		structure,
+		{Name: "type-token-ratio", Value: typeTokenRatio(text, uniformity)},
+		{Name: "sentence-uniformity", Value: uniformity},
+		{Name: "word-length", Value: averageWordLength(text)},
+	}
+	if ex.Language.IsCode() {
+		restated, restatedHits := restatedComments(ex)
+		signals = append(signals,
+			Signal{Name: "comment-ratio", Value: commentRatio(ex)},
+			Signal{Name: "restated-comments", Value: restated, Matches: restatedHits},
+		)
+	}
+	for i := range signals {
+		signals[i].Weight = h.Config.weight(signals[i].Name)
 	}
 
 	var total, wsum float64
-	for _, s := range weights {
-		total += s.v * s.w
-		wsum += s.w
+	for _, s := range signals {
+		total += s.Value * s.Weight
+		wsum += s.Weight
+	}
+	if wsum == 0 {
+		return 0, signals
+	}
+	for i := range signals {
+		signals[i].Contribution = signals[i].Value * signals[i].Weight / wsum
 	}
 	raw := total / wsum
 
 	// sigmoid-like squash so partial signals don't easily tip the threshold
-	return sigmoidNorm(raw)
-}
-
-// phraseDensity returns the fraction of sentences containing an LLM-overused phrase.
-func (h *HeuristicScanner) phraseDensity(text string) float64 {
-	phrases := []string{
-		"it's worth noting",
-		"it is worth noting",
-		"as an ai",
-		"as an ai language model",
-		"i cannot provide",
-		"i'm unable to",
-		"i am unable to",
-		"delve into",
-		"dive into",
-		"in conclusion",
-		"in summary",
-		"to summarize",
-		"let's explore",
-		"let us explore",
-		"it is important to note",
-		"it's important to note",
-		"please note that",
-		"feel free to",
-		"i hope this helps",
-		"certainly!",
-		"of course!",
-		"absolutely!",
-		"great question",
-		"this is a great",
-		"comprehensive guide",
-		"step-by-step",
-		"step by step",
-		"furthermore,",
-		"additionally,",
-		"in the realm of",
-		"leveraging",
-		"utilize",
-		"robust solution",
-		"seamlessly",
-		"cutting-edge",
-	}
+	return h.Config.Sigmoid.norm(raw), signals
+}
 
+// defaultPhrases are the built-in phrases LLMs overuse, matched
+// case-insensitively by phraseDensity.
+var defaultPhrases = []string{
+	"it's worth noting",
+	"it is worth noting",
+	"as an ai",
+	"as an ai language model",
+	"i cannot provide",
+	"i'm unable to",
+	"i am unable to",
+	"delve into",
+	"dive into",
+	"in conclusion",
+	"in summary",
+	"to summarize",
+	"let's explore",
+	"let us explore",
+	"it is important to note",
+	"it's important to note",
+	"please note that",
+	"feel free to",
+	"i hope this helps",
+	"certainly!",
+	"of course!",
+	"absolutely!",
+	"great question",
+	"this is a great",
+	"comprehensive guide",
+	"step-by-step",
+	"step by step",
+	"furthermore,",
+	"additionally,",
+	"in the realm of",
+	"leveraging",
+	"utilize",
+	"robust solution",
+	"seamlessly",
+	"cutting-edge",
+}
+
+// defaultHedges are the built-in meta-commentary phrases matched by
+// hedgeDensity.
+var defaultHedges = []string{
+	"as mentioned",
+	"as noted above",
+	"as discussed",
+	"as outlined",
+	"this ensures that",
+	"this allows you to",
+	"this will allow",
+	"this helps to",
+	"this approach ensures",
+	"by doing so",
+	"in other words",
+	"to put it simply",
+	"put simply",
+	"to clarify",
+	"that being said",
+	"with that said",
+	"having said that",
+	"needless to say",
+}
+
+// phraseDensity returns the fraction of sentences containing an LLM-overused
+// phrase, along with the first matching phrase of each hit sentence.
+func (h *HeuristicScanner) phraseDensity(text string) (float64, []Match) {
 	lower := strings.ToLower(text)
 	sentences := splitSentences(lower)
 	if len(sentences) == 0 {
-		return 0
+		return 0, nil
 	}
 
-	hits := 0
-	for _, s := range sentences {
-		for _, p := range phrases {
-			if strings.Contains(s, p) {
-				hits++
-				break // count each sentence at most once
-			}
-		}
-	}
-	return math.Min(float64(hits)/float64(len(sentences))*3, 1.0)
+	matches := matchSentences(sentences, h.Config.phrases())
+	return math.Min(float64(len(matches))/float64(len(sentences))*3, 1.0), matches
 }
 
 // hedgeDensity looks for meta-commentary patterns LLMs use about their own output.
-func (h *HeuristicScanner) hedgeDensity(text string) float64 {
-	hedges := []string{
-		"as mentioned",
-		"as noted above",
-		"as discussed",
-		"as outlined",
-		"this ensures that",
-		"this allows you to",
-		"this will allow",
-		"this helps to",
-		"this approach ensures",
-		"by doing so",
-		"in other words",
-		"to put it simply",
-		"put simply",
-		"to clarify",
-		"that being said",
-		"with that said",
-		"having said that",
-		"needless to say",
-	}
-
+func (h *HeuristicScanner) hedgeDensity(text string) (float64, []Match) {
 	lower := strings.ToLower(text)
 	sentences := splitSentences(lower)
 	if len(sentences) == 0 {
-		return 0
+		return 0, nil
 	}
 
-	hits := 0
+	matches := matchSentences(sentences, h.Config.hedges())
+	return math.Min(float64(len(matches))/float64(len(sentences))*4, 1.0), matches
+}
+
+// matchSentences returns one Match per sentence that contains any of
+// phrases, naming the first phrase found.
+func matchSentences(sentences []sentence, phrases []string) []Match {
+	var matches []Match
 	for _, s := range sentences {
-		for _, p := range hedges {
-			if strings.Contains(s, p) {
-				hits++
-				break
+		for _, p := range phrases {
+			if strings.Contains(s.text, p) {
+				matches = append(matches, Match{Line: s.line, Phrase: p})
+				break // count each sentence at most once
 			}
 		}
 	}
-	return math.Min(float64(hits)/float64(len(sentences))*4, 1.0)
+	return matches
 }
 
 // bulletHeaderDensity measures how much of the text is markdown structural elements.
 func (h *HeuristicScanner) bulletHeaderDensity(text string) float64 {
 	lines := strings.Split(text, "\n")
-	if len(lines) == 0 {
-		return 0
-	}
 
-	structural := 0
+	structural, nonBlank := 0, 0
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" {
 			continue
 		}
+		nonBlank++
 		if strings.HasPrefix(trimmed, "#") ||
 			strings.HasPrefix(trimmed, "- ") ||
 			strings.HasPrefix(trimmed, "* ") ||
@@ -175,28 +319,47 @@ func (h *HeuristicScanner) bulletHeaderDensity(text string) float64 {
 			structural++
 		}
 	}
-	ratio := float64(structural) / float64(len(lines))
+	if nonBlank == 0 {
+		return 0
+	}
+	// Blank lines are left out of the ratio: in the prose view of a code
+	// file every code line is blank.
+	ratio := float64(structural) / float64(nonBlank)
 	// A ratio above 0.4 is suspicious (very heavily structured).
 	return math.Min(ratio/0.4, 1.0)
 }
 
+// sentence is a rough sentence chunk and the 1-based line it starts on.
+type sentence struct {
+	text string
+	line int
+}
+
 // splitSentences splits text into rough sentence chunks.
-func splitSentences(text string) []string {
-	var sentences []string
+func splitSentences(text string) []sentence {
+	var sentences []sentence
 	var buf strings.Builder
+	line, start := 1, 0
+	flush := func() {
+		if s := strings.TrimFunc(buf.String(), unicode.IsSpace); len(s) > 8 {
+			sentences = append(sentences, sentence{text: s, line: start})
+		}
+		buf.Reset()
+		start = 0
+	}
 	for _, r := range text {
+		if start == 0 && !unicode.IsSpace(r) {
+			start = line
+		}
 		buf.WriteRune(r)
+		if r == '\n' {
+			line++
+		}
 		if r == '.' || r == '!' || r == '?' || r == '\n' {
-			s := strings.TrimFunc(buf.String(), unicode.IsSpace)
-			if len(s) > 8 {
-				sentences = append(sentences, s)
-			}
-			buf.Reset()
+			flush()
 		}
 	}
-	if s := strings.TrimFunc(buf.String(), unicode.IsSpace); len(s) > 8 {
-		sentences = append(sentences, s)
-	}
+	flush()
 	return sentences
 }
 
@@ -204,5 +367,9 @@ func splitSentences(text string) []string {
 // so low signals produce scores well below 0.5 and high signals push above it.
 func sigmoidNorm(x float64) float64 {
 	// logistic: 1/(1+exp(-k*(x-x0)))  with k=8, x0=0.35
-	return 1.0 / (1.0 + math.Exp(-8.0*(x-0.35)))
+	return sigmoidCurve(x, 8.0, 0.35)
+}
+
+func sigmoidCurve(x, k, x0 float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-k*(x-x0)))
 }
@@ -0,0 +1,42 @@
+package aiscan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHeuristicLoadMarkdown(t *testing.T) {
+	calls := 0
+	h := &HeuristicScanner{LoadMarkdown: func() (*MarkdownProfile, error) {
+		calls++
+		p := BuildMarkdownProfile(nil)
+		return &p, nil
+	}}
+	if _, _, err := h.Scan("main.go", []byte("package main\n\n// Entry point.\nfunc main() {}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Fatalf("LoadMarkdown called for a Go file")
+	}
+	for _, p := range []string{"README.md", "docs/guide.md"} {
+		if _, _, err := h.Scan(p, []byte("# Title\n\nSome prose.\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 || h.Markdown == nil {
+		t.Errorf("LoadMarkdown called %d times, profile %v; want once", calls, h.Markdown)
+	}
+}
+
+func TestHeuristicLoadMarkdownError(t *testing.T) {
+	boom := errors.New("boom")
+	h := &HeuristicScanner{LoadMarkdown: func() (*MarkdownProfile, error) { return nil, boom }}
+	if _, _, err := h.Scan("a.txt", []byte("plain text")); err != nil {
+		t.Errorf("non-Markdown scan failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := h.Scan("README.md", []byte("# Title\n")); !errors.Is(err, boom) {
+			t.Errorf("Markdown scan %d error = %v, want %v", i, err, boom)
+		}
+	}
+}
@@ -2,11 +2,54 @@ package aiscan
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 )
 
+func init() {
+	Register("http", newHTTPScanner)
+}
+
+// httpEnv is the http backend's config.
+type httpEnv struct {
+	Endpoint      string        `env:"AI_SCAN_ENDPOINT" help:"URL to POST each file to (required)"`
+	Token         string        `env:"AI_SCAN_TOKEN" help:"credential sent with each request"`
+	AuthHeader    string        `env:"AI_SCAN_AUTH_HEADER" default:"Authorization" help:"header carrying the token; Authorization sends \"Bearer <token>\", others the raw token"`
+	Timeout       time.Duration `env:"AI_SCAN_TIMEOUT" default:"30s" help:"per-attempt timeout"`
+	Retries       int           `env:"AI_SCAN_RETRIES" default:"2" help:"retries for connection errors, 429 and 5xx responses"`
+	BatchEndpoint string        `env:"AI_SCAN_BATCH_ENDPOINT" help:"URL accepting many files per request; unset means one request per file"`
+	BatchSize     int           `env:"AI_SCAN_BATCH_SIZE" default:"50" help:"files per batch request"`
+}
+
+func newHTTPScanner(cfg httpEnv, _ RepoConfig) (Scanner, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("AI_SCAN_BACKEND=http requires AI_SCAN_ENDPOINT to be set")
+	}
+	if cfg.Timeout <= 0 {
+		return nil, fmt.Errorf("invalid AI_SCAN_TIMEOUT %s (must be positive)", cfg.Timeout)
+	}
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("invalid AI_SCAN_RETRIES %d", cfg.Retries)
+	}
+	if cfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("invalid AI_SCAN_BATCH_SIZE %d", cfg.BatchSize)
+	}
+	return &HTTPScanner{
+		Endpoint:      cfg.Endpoint,
+		BatchEndpoint: cfg.BatchEndpoint,
+		BatchSize:     cfg.BatchSize,
+		Token:         cfg.Token,
+		AuthHeader:    cfg.AuthHeader,
+		Timeout:       cfg.Timeout,
+		Retries:       cfg.Retries,
+	}, nil
+}
+
 // HTTPScanner POSTs file content to an external AI-detection service and
 // interprets its JSON response.
 //
@@ -18,12 +61,54 @@ import (
 //
 //	{"likely_ai": <bool>, "confidence": <float 0-1>}
 //
-// Any non-2xx response is treated as a scan error (not a positive detection).
+// Any non-2xx response, or a body over 4 MiB, is treated as a scan error
+// (not a positive detection).
+// Connection errors, 429 and 5xx responses are retried up to Retries times
+// with exponential backoff; other 4xx responses fail immediately.
 type HTTPScanner struct {
 	// Endpoint is the full URL to POST to (from AI_SCAN_ENDPOINT).
 	Endpoint string
+	// BatchEndpoint, when set, enables ScanBatch (from
+	// AI_SCAN_BATCH_ENDPOINT).
+	BatchEndpoint string
+	// BatchSize caps the files sent per batch request (from
+	// AI_SCAN_BATCH_SIZE).  Zero means 50.
+	BatchSize int
+
+	// Token, when set, is sent with every request (from AI_SCAN_TOKEN).
+	// With the default AuthHeader it is sent as "Authorization: Bearer
+	// <Token>"; with any other header name it is sent verbatim, which suits
+	// API-key schemes such as "X-API-Key".
+	Token string
+	// AuthHeader names the header carrying Token (from
+	// AI_SCAN_AUTH_HEADER); empty means "Authorization".
+	AuthHeader string
+
+	// Timeout bounds each attempt, including reading the response
+	// (from AI_SCAN_TIMEOUT).  Zero means 30s.
+	Timeout time.Duration
+	// Retries is the number of extra attempts after a retryable failure
+	// (from AI_SCAN_RETRIES).
+	Retries int
+	// Backoff is the delay before the first retry; it doubles on each
+	// subsequent retry, up to maxBackoff.  Zero means 500ms.
+	Backoff time.Duration
+
+	// Client is used for requests; nil means http.DefaultClient.  Timeout
+	// applies on top of any timeout the client sets.
+	Client *http.Client
 }
 
+const (
+	defaultHTTPTimeout = 30 * time.Second
+	defaultHTTPBackoff = 500 * time.Millisecond
+	maxBackoff         = 10 * time.Second
+
+	// maxHTTPResponse caps the response body read; a detector has no
+	// reason to send more than a verdict per file.
+	maxHTTPResponse = 4 << 20
+)
+
 type httpRequest struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
@@ -34,7 +119,19 @@ type httpResponse struct {
 	Confidence float64 `json:"confidence"`
 }
 
+// Fingerprint identifies the detection service by its endpoint.
+func (s *HTTPScanner) Fingerprint() string { return "http/" + s.Endpoint }
+
+// PathSensitive reports true: the service receives each file's path.
+func (s *HTTPScanner) PathSensitive() bool { return true }
+
 func (s *HTTPScanner) Scan(path string, content []byte) (bool, float64, error) {
+	return s.ScanContext(context.Background(), path, content)
+}
+
+// ScanContext is Scan with cancellation: ctx aborts the in-flight request
+// and any pending retry.
+func (s *HTTPScanner) ScanContext(ctx context.Context, path string, content []byte) (bool, float64, error) {
 	body, err := json.Marshal(httpRequest{
 		Path:    path,
 		Content: string(content),
@@ -43,20 +140,98 @@ func (s *HTTPScanner) Scan(path string, content []byte) (bool, float64, error) {
 		return false, 0, fmt.Errorf("aiscan/http: marshal request: %w", err)
 	}
 
-	resp, err := http.Post(s.Endpoint, "application/json", bytes.NewReader(body)) //nolint:noctx
+	var result httpResponse
+	if err := s.post(ctx, s.Endpoint, body, &result); err != nil {
+		return false, 0, err
+	}
+	return result.LikelyAI, result.Confidence, nil
+}
+
+// post sends body to url, retrying retryable failures, and decodes a 2xx
+// JSON response into out.
+func (s *HTTPScanner) post(ctx context.Context, url string, body []byte, out any) error {
+	backoff := s.Backoff
+	if backoff <= 0 {
+		backoff = defaultHTTPBackoff
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("aiscan/http: %w (last error: %v)", ctx.Err(), lastErr)
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+
+		retry, err := s.attempt(ctx, url, body, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	if s.Retries > 0 {
+		return fmt.Errorf("%w (after %d attempt(s))", lastErr, s.Retries+1)
+	}
+	return lastErr
+}
+
+// attempt makes one request.  retry reports whether a failure is worth
+// retrying.
+func (s *HTTPScanner) attempt(ctx context.Context, url string, body []byte, out any) (retry bool, err error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return false, 0, fmt.Errorf("aiscan/http: POST %s: %w", s.Endpoint, err)
+		return false, fmt.Errorf("aiscan/http: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		switch {
+		case s.AuthHeader == "" || http.CanonicalHeaderKey(s.AuthHeader) == "Authorization":
+			req.Header.Set("Authorization", "Bearer "+s.Token)
+		default:
+			req.Header.Set(s.AuthHeader, s.Token)
+		}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Connection failures and per-attempt timeouts are retryable;
+		// cancellation of the caller's context is not (post checks it).
+		return true, fmt.Errorf("aiscan/http: POST %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return false, 0, fmt.Errorf("aiscan/http: server returned %s", resp.Status)
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("aiscan/http: server returned %s", resp.Status)
 	}
 
-	var result httpResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return false, 0, fmt.Errorf("aiscan/http: decode response: %w", err)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponse+1))
+	if err != nil {
+		return errors.Is(err, context.DeadlineExceeded), fmt.Errorf("aiscan/http: read response: %w", err)
 	}
-
-	return result.LikelyAI, result.Confidence, nil
+	if len(data) > maxHTTPResponse {
+		return false, fmt.Errorf("aiscan/http: response exceeds %d bytes", maxHTTPResponse)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("aiscan/http: decode response: %w", err)
+	}
+	return false, nil
 }
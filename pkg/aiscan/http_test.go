@@ -0,0 +1,204 @@
+package aiscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// detector serves h and counts the requests it receives.
+func detector(t *testing.T, h http.HandlerFunc) (*HTTPScanner, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		h(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	s := &HTTPScanner{Endpoint: srv.URL, Timeout: time.Second, Backoff: time.Millisecond}
+	return s, &calls
+}
+
+func TestHTTPScanner(t *testing.T) {
+	s, calls := detector(t, func(w http.ResponseWriter, r *http.Request) {
+		var req httpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			http.Error(w, "bad auth "+got, http.StatusUnauthorized)
+			return
+		}
+		ai := req.Path == "ai.md" && req.Content == "text"
+		json.NewEncoder(w).Encode(httpResponse{LikelyAI: ai, Confidence: 0.75})
+	})
+	s.Token = "secret"
+	ai, conf, err := s.Scan("ai.md", []byte("text"))
+	if err != nil || !ai || conf != 0.75 {
+		t.Fatalf("Scan = %v, %v, %v; want true, 0.75, nil", ai, conf, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestHTTPScannerAPIKeyHeader(t *testing.T) {
+	s, _ := detector(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "k" || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"likely_ai": false, "confidence": 0.1}`))
+	})
+	s.Token, s.AuthHeader = "k", "X-API-Key"
+	if _, _, err := s.Scan("a.go", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPScannerFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		retries int
+		calls   int32
+		want    string
+	}{
+		{
+			name:    "client error is not retried",
+			handler: func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusBadRequest) },
+			retries: 2,
+			calls:   1,
+			want:    "400 Bad Request",
+		},
+		{
+			name:    "server errors exhaust retries",
+			handler: func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			retries: 2,
+			calls:   3,
+			want:    "502 Bad Gateway (after 3 attempt(s))",
+		},
+		{
+			name:    "rate limit is retried",
+			handler: func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTooManyRequests) },
+			retries: 1,
+			calls:   2,
+			want:    "429 Too Many Requests",
+		},
+		{
+			name:    "timeout",
+			handler: func(http.ResponseWriter, *http.Request) { time.Sleep(300 * time.Millisecond) },
+			retries: 1,
+			calls:   2,
+			want:    "deadline exceeded",
+		},
+		{
+			name:    "malformed JSON",
+			handler: func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte(`{"likely_ai": tru`)) },
+			retries: 2,
+			calls:   1,
+			want:    "decode response",
+		},
+		{
+			name: "oversized body",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"likely_ai": true, "pad": "` + strings.Repeat("x", maxHTTPResponse) + `"}`))
+			},
+			calls: 1,
+			want:  "exceeds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, calls := detector(t, tt.handler)
+			s.Retries = tt.retries
+			s.Timeout = 100 * time.Millisecond
+			ai, _, err := s.Scan("a.md", []byte("text"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Scan error = %v, want %q", err, tt.want)
+			}
+			if ai {
+				t.Errorf("failed Scan reported likely AI")
+			}
+			if n := calls.Load(); n != tt.calls {
+				t.Errorf("%d requests, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestHTTPScannerRecovers(t *testing.T) {
+	var fail atomic.Int32
+	fail.Store(2)
+	s, calls := detector(t, func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"likely_ai": true, "confidence": 0.9}`))
+	})
+	s.Retries = 2
+	if ai, _, err := s.Scan("a.md", nil); err != nil || !ai {
+		t.Fatalf("Scan = %v, %v; want success on the third attempt", ai, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+}
+
+// A failed chunk fails only its own files.
+func TestHTTPScannerBatchChunkFailure(t *testing.T) {
+	s, calls := detector(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqs []httpRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var items []batchResponseItem
+		for _, req := range reqs {
+			switch req.Path {
+			case "c.go":
+				http.Error(w, "rejected", http.StatusBadRequest)
+				return
+			case "e.go":
+				items = append(items, batchResponseItem{Error: "too large"})
+			default:
+				items = append(items, batchResponseItem{httpResponse: httpResponse{Confidence: 0.25}})
+			}
+		}
+		json.NewEncoder(w).Encode(items)
+	})
+	s.BatchEndpoint, s.BatchSize = s.Endpoint, 2
+	files := []File{{Path: "a.go"}, {Path: "b.go"}, {Path: "c.go"}, {Path: "d.go"}, {Path: "e.go"}}
+	results := ScanAll(s, files)
+	for i, wantErr := range []string{"", "", "400", "400", "too large"} {
+		r := results[i]
+		switch {
+		case wantErr == "" && (r.Err != nil || r.Confidence != 0.25):
+			t.Errorf("%s = %+v, want confidence 0.25", files[i].Path, r)
+		case wantErr != "" && (r.Err == nil || !strings.Contains(r.Err.Error(), wantErr)):
+			t.Errorf("%s error = %v, want %q", files[i].Path, r.Err, wantErr)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range ScanAllContext(ctx, s, files) {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("cancelled batch: error = %v, want context.Canceled", r.Err)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("cancelled batch sent %d request(s)", n-3)
+	}
+}
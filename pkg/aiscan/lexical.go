@@ -0,0 +1,143 @@
+package aiscan
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// lexicalMinWords is the minimum amount of prose the lexical signals need;
+// below it TTR and sentence statistics are too noisy to mean anything.
+const lexicalMinWords = 100
+
+// mattrWindow is the window size for the moving-average type-token ratio.
+// A plain TTR falls as text grows; averaging over fixed windows removes
+// that length bias.
+const mattrWindow = 50
+
+// typeTokenRatio scores how closely the moving-average type-token ratio of
+// text sits in the moderate band typical of LLM prose (0.75–0.95 with a
+// 50-word window, peaking at 0.85).  On its own that band also covers a lot
+// of human writing, so the score is scaled by uniformity (the burstiness
+// signal): a moderate TTR only counts when sentence lengths are uniform too.
+func typeTokenRatio(text string, uniformity float64) float64 {
+	words := proseWords(text)
+	if len(words) < lexicalMinWords {
+		return 0
+	}
+	mattr := movingTTR(words, mattrWindow)
+	return math.Max(0, 1-math.Abs(mattr-0.85)/0.10) * uniformity
+}
+
+// movingTTR averages the type-token ratio of every window-sized run of words.
+func movingTTR(words []string, window int) float64 {
+	if len(words) <= window {
+		return distinctRatio(words)
+	}
+	counts := map[string]int{}
+	for _, w := range words[:window] {
+		counts[w]++
+	}
+	sum := float64(len(counts)) / float64(window)
+	n := 1
+	for i := window; i < len(words); i++ {
+		out := words[i-window]
+		if counts[out]--; counts[out] == 0 {
+			delete(counts, out)
+		}
+		counts[words[i]]++
+		sum += float64(len(counts)) / float64(window)
+		n++
+	}
+	return sum / float64(n)
+}
+
+func distinctRatio(words []string) float64 {
+	if len(words) == 0 {
+		return 0
+	}
+	seen := map[string]bool{}
+	for _, w := range words {
+		seen[w] = true
+	}
+	return float64(len(seen)) / float64(len(words))
+}
+
+// burstiness scores how uniform sentence lengths are.  People mix short and
+// long sentences (coefficient of variation of words per sentence around 0.6
+// or more); LLM output keeps them close to the mean (0.3–0.45).  A low
+// variation therefore scores high.
+func burstiness(text string) float64 {
+	if len(proseWords(text)) < lexicalMinWords {
+		return 0
+	}
+	var lengths []float64
+	for _, s := range proseSentences(text) {
+		if n := len(proseWords(s)); n >= 3 {
+			lengths = append(lengths, float64(n))
+		}
+	}
+	if len(lengths) < 5 {
+		return 0
+	}
+	var mean float64
+	for _, l := range lengths {
+		mean += l
+	}
+	mean /= float64(len(lengths))
+	var variance float64
+	for _, l := range lengths {
+		variance += (l - mean) * (l - mean)
+	}
+	variance /= float64(len(lengths))
+	cv := math.Sqrt(variance) / mean
+	return math.Max(0, math.Min((0.6-cv)/0.3, 1.0))
+}
+
+// averageWordLength scores the mean word length of text.  LLM prose leans on
+// long Latinate vocabulary ("comprehensive", "functionality") and averages
+// above five letters per word; everyday human prose sits nearer 4.5.
+func averageWordLength(text string) float64 {
+	words := proseWords(text)
+	if len(words) < lexicalMinWords {
+		return 0
+	}
+	letters := 0
+	for _, w := range words {
+		letters += len([]rune(w))
+	}
+	avg := float64(letters) / float64(len(words))
+	return math.Max(0, math.Min((avg-4.6)/0.8, 1.0))
+}
+
+// proseWords returns the lower-cased alphabetic words of text.  Apostrophes
+// inside a word are kept so "don't" stays one word.
+func proseWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+}
+
+// proseSentences splits text on sentence-ending punctuation and blank lines.
+// Unlike splitSentences, a single line break does not end a sentence, so
+// hard-wrapped paragraphs and comments are measured as written rather than
+// as equal-width lines.
+func proseSentences(text string) []string {
+	var sentences []string
+	for _, para := range strings.Split(text, "\n\n") {
+		var buf strings.Builder
+		runes := []rune(strings.Join(strings.Fields(para), " "))
+		for i, r := range runes {
+			buf.WriteRune(r)
+			end := r == '.' || r == '!' || r == '?'
+			if end && (i+1 == len(runes) || runes[i+1] == ' ') {
+				sentences = append(sentences, buf.String())
+				buf.Reset()
+			}
+		}
+		if s := strings.TrimSpace(buf.String()); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	return sentences
+}
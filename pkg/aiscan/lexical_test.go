@@ -0,0 +1,75 @@
+package aiscan
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lexicalScore is the unweighted mean of the three lexical signals, the
+// share of the heuristic score they are responsible for.
+func lexicalScore(text string) float64 {
+	u := burstiness(text)
+	return (typeTokenRatio(text, u) + u + averageWordLength(text)) / 3
+}
+
+// The samples under testdata/lexical are the same subjects written by a
+// person and by an LLM; the file name says which.
+func TestLexicalSamples(t *testing.T) {
+	files, err := filepath.Glob("testdata/lexical/*.txt")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := string(data)
+			if n := len(proseWords(text)); n < lexicalMinWords {
+				t.Fatalf("sample has %d words, want at least %d", n, lexicalMinWords)
+			}
+			wantAI := strings.HasPrefix(filepath.Base(f), "llm")
+			if got := lexicalScore(text); (got >= 0.5) != wantAI {
+				t.Errorf("lexical score = %.2f, want AI = %v (burstiness %.2f, word length %.2f)",
+					got, wantAI, burstiness(text), averageWordLength(text))
+			}
+		})
+	}
+}
+
+func TestLexicalMinWords(t *testing.T) {
+	short := strings.Repeat("The build passed. ", 10)
+	if got := lexicalScore(short); got != 0 {
+		t.Errorf("lexical score of %d words = %.2f, want 0", len(proseWords(short)), got)
+	}
+}
+
+func TestMovingTTR(t *testing.T) {
+	var words []string
+	for i := 0; i < 400; i++ {
+		words = append(words, string(rune('a'+i%26)))
+	}
+	// 26 distinct words repeated: every 50-word window holds all 26.
+	if got := movingTTR(words, 50); math.Abs(got-26.0/50) > 1e-9 {
+		t.Errorf("movingTTR = %v, want %v", got, 26.0/50)
+	}
+	if got := movingTTR(words[:10], 50); got != 1 {
+		t.Errorf("movingTTR of a short run = %v, want 1", got)
+	}
+}
+
+func TestProseSentences(t *testing.T) {
+	text := "One sentence\nwrapped over lines. Two? Three!\n\nA paragraph without a stop"
+	want := []string{"One sentence wrapped over lines.", "Two?", "Three!", "A paragraph without a stop"}
+	got := proseSentences(text)
+	for i := range got {
+		got[i] = strings.TrimSpace(got[i])
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("proseSentences = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,296 @@
+package aiscan
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// BlockKind classifies a Markdown block.
+type BlockKind string
+
+const (
+	BlockHeading   BlockKind = "heading"
+	BlockList      BlockKind = "list"
+	BlockTable     BlockKind = "table"
+	BlockFence     BlockKind = "fence"
+	BlockParagraph BlockKind = "paragraph" // also block quotes and raw HTML
+)
+
+// Block is one top-level Markdown block found by ParseMarkdown.
+type Block struct {
+	Kind BlockKind
+	// Line and EndLine are the 1-based first and last lines of the block.
+	Line, EndLine int
+	// Lines holds the block's source lines without their line breaks.
+	Lines []string
+	// Items counts the items of a list block.
+	Items int
+}
+
+var (
+	atxHeading    = regexp.MustCompile(`^#{1,6}(\s|$)`)
+	listMarker    = regexp.MustCompile(`^([-*+]|\d{1,9}[.)])(\s+|$)`)
+	tableDelim    = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)+\|?$`)
+	thematicBreak = regexp.MustCompile(`^([-*_])(\s*([-*_]))*$`)
+	setextLine    = regexp.MustCompile(`^(=+|-+)$`)
+	boldLead      = regexp.MustCompile(`^(\*\*|__)[^*_]+(\*\*|__)\s*[:—–-]?`)
+)
+
+// ParseMarkdown splits content into blocks.  It is a line-based
+// approximation of CommonMark that is good enough to tell prose from
+// structure: ATX and setext headings, bullet and ordered lists (with their
+// continuation lines), pipe tables, ``` and ~~~ fences, and paragraphs.
+// Blank lines and thematic breaks belong to no block.
+func ParseMarkdown(content []byte) []Block {
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	var (
+		blocks []Block
+		cur    *Block
+		fence  string
+	)
+	open := func(kind BlockKind, n int) {
+		blocks = append(blocks, Block{Kind: kind, Line: n, EndLine: n})
+		cur = &blocks[len(blocks)-1]
+	}
+	add := func(line string, n int) {
+		cur.Lines = append(cur.Lines, strings.TrimSuffix(line, "\r"))
+		cur.EndLine = n
+	}
+	for i, line := range lines {
+		n := i + 1
+		trimmed := strings.TrimSpace(line)
+		indented := strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+
+		if fence != "" {
+			add(line, n)
+			if strings.HasPrefix(trimmed, fence) {
+				fence, cur = "", nil
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			open(BlockFence, n)
+			add(line, n)
+		case trimmed == "":
+			// A blank line ends everything but a list, which may go on
+			// with another item or an indented continuation.
+			if cur != nil && cur.Kind != BlockList {
+				cur = nil
+			}
+		case atxHeading.MatchString(trimmed):
+			open(BlockHeading, n)
+			add(line, n)
+			cur = nil
+		case cur != nil && cur.Kind == BlockParagraph && setextLine.MatchString(trimmed):
+			cur.Kind = BlockHeading
+			add(line, n)
+			cur = nil
+		case thematicBreak.MatchString(trimmed) && len(strings.Trim(trimmed, " ")) >= 3:
+			cur = nil
+		case listMarker.MatchString(trimmed) && !indented:
+			if cur == nil || cur.Kind != BlockList {
+				open(BlockList, n)
+			}
+			cur.Items++
+			add(line, n)
+		case cur != nil && cur.Kind == BlockList && (indented || cur.EndLine == n-1):
+			add(line, n) // continuation (or lazy continuation) of an item
+		case strings.HasPrefix(trimmed, "|") || tableDelim.MatchString(trimmed):
+			if cur != nil && cur.Kind == BlockParagraph && len(cur.Lines) == 1 && tableDelim.MatchString(trimmed) {
+				cur.Kind = BlockTable // header row without a leading pipe
+			} else if cur == nil || cur.Kind != BlockTable {
+				open(BlockTable, n)
+			}
+			add(line, n)
+		case cur != nil && cur.Kind == BlockTable && strings.Contains(trimmed, "|"):
+			add(line, n)
+		default:
+			if cur == nil || cur.Kind != BlockParagraph {
+				open(BlockParagraph, n)
+			}
+			add(line, n)
+		}
+	}
+	return blocks
+}
+
+// markdownProse keeps the paragraphs and list items of a Markdown document
+// and blanks headings, tables and fenced code, preserving the line layout.
+func markdownProse(content []byte, blocks []Block) string {
+	lines := strings.SplitAfter(string(content), "\n")
+	for _, b := range blocks {
+		if b.Kind == BlockParagraph || b.Kind == BlockList {
+			continue
+		}
+		for n := b.Line; n <= b.EndLine && n <= len(lines); n++ {
+			lines[n-1] = blankLine(lines[n-1])
+		}
+	}
+	// Thematic breaks are in no block; blank them too so "---" is not
+	// read as prose.
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) >= 3 && thematicBreak.MatchString(trimmed) && !inBlock(blocks, i+1) {
+			lines[i] = blankLine(line)
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func inBlock(blocks []Block, n int) bool {
+	for _, b := range blocks {
+		if n >= b.Line && n <= b.EndLine {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkdownStats are the structural measurements of one Markdown document
+// that the markdown-structure signal compares against a MarkdownProfile.
+type MarkdownStats struct {
+	// Lines counts the non-blank lines of headings, lists and paragraphs;
+	// fences and tables are left out.
+	Lines int
+	// Structure is the share of Lines that are headings or list items.
+	Structure float64
+	// BoldLead is the share of list items that open with a bold term
+	// ("- **Speed**: ...").
+	BoldLead float64
+	// SectionWords is the number of paragraph words per heading.
+	SectionWords float64
+}
+
+// markdownStats measures blocks.
+func markdownStats(blocks []Block) MarkdownStats {
+	var lines, structural, items, bold, headings, words int
+	for _, b := range blocks {
+		switch b.Kind {
+		case BlockHeading:
+			headings++
+			lines++
+			structural++
+		case BlockList:
+			for _, l := range b.Lines {
+				if strings.TrimSpace(l) == "" {
+					continue
+				}
+				lines++
+				trimmed := strings.TrimSpace(l)
+				if m := listMarker.FindString(trimmed); m != "" {
+					structural++
+					items++
+					if boldLead.MatchString(strings.TrimSpace(trimmed[len(m):])) {
+						bold++
+					}
+				}
+			}
+		case BlockParagraph:
+			lines += len(b.Lines)
+			for _, l := range b.Lines {
+				words += len(strings.Fields(l))
+			}
+		}
+	}
+	st := MarkdownStats{Lines: lines}
+	if lines > 0 {
+		st.Structure = float64(structural) / float64(lines)
+	}
+	if items > 0 {
+		st.BoldLead = float64(bold) / float64(items)
+	}
+	st.SectionWords = float64(words) / float64(headings+1)
+	return st
+}
+
+// MarkdownProfile describes how a repository's own documentation is
+// structured: the mean and spread of each MarkdownStats measurement over
+// its existing Markdown files.  Build one with BuildMarkdownProfile.
+type MarkdownProfile struct {
+	Docs         int  `json:"docs"`
+	Structure    Stat `json:"structure"`
+	BoldLead     Stat `json:"bold-lead"`
+	SectionWords Stat `json:"section-words"`
+}
+
+// Stat is a mean and standard deviation.
+type Stat struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+}
+
+// markdownMinLines is the size below which a document is too short for its
+// structure to say anything, both when profiling and when scoring.
+const markdownMinLines = 10
+
+// markdownMinDocs is the number of profiled documents below which the
+// built-in profile is used instead.
+const markdownMinDocs = 3
+
+// defaultMarkdownProfile stands in for repos with too few docs.  It is
+// deliberately lenient: a README that is half headings and bullets sits
+// within one deviation.
+var defaultMarkdownProfile = MarkdownProfile{
+	Structure:    Stat{Mean: 0.35, StdDev: 0.15},
+	BoldLead:     Stat{Mean: 0.05, StdDev: 0.10},
+	SectionWords: Stat{Mean: 80, StdDev: 50},
+}
+
+// BuildMarkdownProfile profiles docs (the contents of Markdown files),
+// ignoring those shorter than markdownMinLines.
+func BuildMarkdownProfile(docs [][]byte) MarkdownProfile {
+	var all []MarkdownStats
+	for _, d := range docs {
+		if st := markdownStats(ParseMarkdown(d)); st.Lines >= markdownMinLines {
+			all = append(all, st)
+		}
+	}
+	p := MarkdownProfile{Docs: len(all)}
+	p.Structure = stat(all, func(s MarkdownStats) float64 { return s.Structure })
+	p.BoldLead = stat(all, func(s MarkdownStats) float64 { return s.BoldLead })
+	p.SectionWords = stat(all, func(s MarkdownStats) float64 { return s.SectionWords })
+	return p
+}
+
+func stat(all []MarkdownStats, f func(MarkdownStats) float64) Stat {
+	v := make([]float64, len(all))
+	for i, s := range all {
+		v[i] = f(s)
+	}
+	return statOf(v)
+}
+
+// markdownStructure scores how far st departs from profile (nil, or one
+// built from fewer than markdownMinDocs docs, means the built-in profile)
+// in the direction LLM output tends to: more headings and bullets, more
+// bold lead-ins, fewer words per section.  Each measurement within one
+// deviation of the repo's norm scores 0 and three deviations out scores 1;
+// the signal is their mean.
+func markdownStructure(st MarkdownStats, profile *MarkdownProfile) float64 {
+	if st.Lines < markdownMinLines {
+		return 0
+	}
+	p := defaultMarkdownProfile
+	if profile != nil && profile.Docs >= markdownMinDocs {
+		p = *profile
+	}
+	z := []float64{
+		deviation(st.Structure-p.Structure.Mean, p.Structure.StdDev, 0.05),
+		deviation(st.BoldLead-p.BoldLead.Mean, p.BoldLead.StdDev, 0.05),
+		deviation(p.SectionWords.Mean-st.SectionWords, p.SectionWords.StdDev, 10),
+	}
+	var sum float64
+	for _, v := range z {
+		sum += math.Max(0, math.Min((v-1)/2, 1))
+	}
+	return sum / float64(len(z))
+}
+
+// deviation is diff in units of stddev, which is floored at min so a
+// uniform set of docs does not make every small difference extreme.
+func deviation(diff, stddev, min float64) float64 {
+	return diff / math.Max(stddev, min)
+}
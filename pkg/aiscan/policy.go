@@ -0,0 +1,149 @@
+package aiscan
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/portal-co/scripts/internal/glob"
+)
+
+// PathRule tells a caller how to treat the files matching Paths.  A rule
+// with neither Skip, Backend nor Threshold set scans matching files with
+// the default scanner, which is how a repo overrides a DefaultPolicy skip.
+//
+// Example (YAML, in the .portal-config.yaml "ai-scan" section):
+//
+//	policy:
+//	  - paths: ["testdata/**", "*.pb.go"]
+//	    skip: true
+//	  - paths: ["docs/**"]
+//	    backend: ensemble
+//	    threshold: 0.7
+type PathRule struct {
+	// Paths are slash-separated globs matched against repo-relative paths.
+	// "*", "?" and "[...]" work as in path.Match within one segment, "**"
+	// matches any number of segments, and a glob without a "/" matches the
+	// base name at any depth.  A trailing "/" matches everything below a
+	// directory.
+	Paths []string `json:"paths" yaml:"paths"`
+	// Skip excludes matching files from the AI scan.
+	Skip bool `json:"skip,omitempty" yaml:"skip,omitempty"`
+	// Backend names the backend (as in AI_SCAN_BACKEND) to scan matching
+	// files with instead of the default.
+	Backend string `json:"backend,omitempty" yaml:"backend,omitempty"`
+	// Threshold replaces the scanner's own verdict: a file is flagged when
+	// its confidence reaches Threshold.  Zero keeps the scanner's verdict.
+	// When the default backend is judged against the repo baseline (a
+	// RelativeScanner), its confidence is Φ(z − Z) rather than an absolute
+	// score: 0.5 is a z-score of exactly Z, and 0.84 one deviation more.
+	// Rules naming a Backend always see that backend's absolute score.
+	Threshold float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+}
+
+// Policy is an ordered list of PathRules; the first rule with a matching
+// glob decides a file's treatment.
+type Policy []PathRule
+
+// defaultSkipped are the extensions DefaultPolicy skips: binary formats the
+// heuristics cannot read, and lock files, which are machine-generated.
+var defaultSkipped = []string{
+	"png", "jpg", "jpeg", "gif", "webp", "ico", "bmp",
+	"pdf", "zip", "tar", "gz", "bz2", "xz", "7z",
+	"wasm", "bin", "exe", "dll", "so", "dylib",
+	"mp3", "mp4", "wav", "ogg", "flac",
+	"ttf", "otf", "woff", "woff2",
+	"lock", // Cargo.lock, package-lock.json etc. are machine-generated
+}
+
+// DefaultPolicy returns the built-in policy: skip well-known non-text and
+// generated files.  Callers append it after a repo's own rules so those
+// take precedence.
+func DefaultPolicy() Policy {
+	// Globs are case-sensitive, but extensions come in any case
+	// (IMG_0001.JPG, Cargo.Lock), so each letter is a two-case class.
+	var globs []string
+	for _, ext := range defaultSkipped {
+		globs = append(globs, "*."+anyCase(ext))
+	}
+	return Policy{{Paths: globs, Skip: true}}
+}
+
+// anyCase turns s into a glob matching it in any letter case: "png"
+// becomes "[pP][nN][gG]".
+func anyCase(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if lo, up := unicode.ToLower(r), unicode.ToUpper(r); lo != up {
+			fmt.Fprintf(&b, "[%c%c]", lo, up)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Lookup returns the first rule matching rel (a slash-separated,
+// repo-relative path) and the glob that matched it.  It returns the zero
+// PathRule and "" when nothing matches.
+func (p Policy) Lookup(rel string) (PathRule, string) {
+	for _, r := range p {
+		for _, g := range r.Paths {
+			if matchGlob(g, rel) {
+				return r, g
+			}
+		}
+	}
+	return PathRule{}, ""
+}
+
+// Validate reports malformed globs, contradictory rules and out-of-range
+// thresholds.  Backend names are checked when the backend is built.
+func (p Policy) Validate() error {
+	for i, r := range p {
+		if len(r.Paths) == 0 {
+			return fmt.Errorf("policy rule %d: no paths", i+1)
+		}
+		for _, g := range r.Paths {
+			if err := checkGlob(g); err != nil {
+				return fmt.Errorf("policy rule %d: %w", i+1, err)
+			}
+		}
+		if r.Skip && (r.Backend != "" || r.Threshold != 0) {
+			return fmt.Errorf("policy rule %d: skip cannot be combined with backend or threshold", i+1)
+		}
+		if r.Threshold < 0 || r.Threshold > 1 {
+			return fmt.Errorf("policy rule %d: threshold %g outside [0,1]", i+1, r.Threshold)
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether rel matches the glob g (see PathRule.Paths).
+func matchGlob(g, rel string) bool {
+	if strings.HasSuffix(g, "/") {
+		g += "**"
+	}
+	if !strings.Contains(g, "/") {
+		ok, _ := path.Match(g, path.Base(rel))
+		return ok
+	}
+	return glob.MatchSegments(strings.Split(strings.TrimPrefix(g, "/"), "/"), strings.Split(rel, "/"))
+}
+
+// checkGlob rejects globs path.Match would fail on.
+func checkGlob(g string) error {
+	if strings.TrimSpace(g) == "" {
+		return fmt.Errorf("empty glob")
+	}
+	for _, seg := range strings.Split(g, "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("bad glob %q: %w", g, err)
+		}
+	}
+	return nil
+}
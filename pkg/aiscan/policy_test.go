@@ -0,0 +1,56 @@
+package aiscan
+
+import "testing"
+
+func TestDefaultPolicySkip(t *testing.T) {
+	p := DefaultPolicy()
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for rel, skip := range map[string]bool{
+		"logo.png":             true,
+		"img/IMG_0001.JPG":     true,
+		"foo.Png":              true,
+		"x.Lock":               true,
+		"Cargo.lock":           true,
+		"web/font.WOFF2":       true,
+		"main.go":              false,
+		"README.md":            false,
+		"png":                  false,
+		"docs/lock.md":         false,
+		"archive.tar.gz":       true,
+		"scripts/build.tar.sh": false,
+	} {
+		if rule, _ := p.Lookup(rel); rule.Skip != skip {
+			t.Errorf("Lookup(%q).Skip = %v, want %v", rel, rule.Skip, skip)
+		}
+	}
+}
+
+func TestPolicyLookup(t *testing.T) {
+	p := append(Policy{
+		{Paths: []string{"testdata/**"}, Skip: true},
+		{Paths: []string{"docs/"}, Backend: "ensemble"},
+		{Paths: []string{"assets/*.png"}}, // scan these after all
+	}, DefaultPolicy()...)
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rel     string
+		skip    bool
+		backend string
+	}{
+		{"testdata/a/b.go", true, ""},
+		{"pkg/testdata/b.go", false, ""},
+		{"docs/guide/intro.md", false, "ensemble"},
+		{"assets/diagram.png", false, ""},
+		{"other/diagram.png", true, ""},
+	}
+	for _, tt := range tests {
+		rule, _ := p.Lookup(tt.rel)
+		if rule.Skip != tt.skip || rule.Backend != tt.backend {
+			t.Errorf("Lookup(%q) = skip %v backend %q, want skip %v backend %q", tt.rel, rule.Skip, rule.Backend, tt.skip, tt.backend)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package aiscan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Profile is a repository baseline: the distribution of a scanner's
+// confidence and signal values over the repo's existing files, grouped by
+// language (see DetectLanguage).  It is built once per anchor commit by
+// BuildProfile and can be saved as JSON and reused.
+type Profile struct {
+	// Fingerprint is the profiled scanner's; a profile is only meaningful
+	// for the scanner that produced it.
+	Fingerprint string `json:"fingerprint"`
+	// Commit is the commit whose files were profiled, if known.
+	Commit string `json:"commit,omitempty"`
+	// Groups is keyed by Language ("" for unrecognised files).
+	Groups map[Language]*ProfileGroup `json:"groups"`
+}
+
+// ProfileGroup is the baseline for one language.
+type ProfileGroup struct {
+	Files      int             `json:"files"`
+	Confidence Stat            `json:"confidence"`
+	Signals    map[string]Stat `json:"signals,omitempty"`
+}
+
+// BuildProfile scans files with s and records, per language, the mean and
+// spread of the confidence and of every signal.  Files the scanner fails
+// on are left out.
+func BuildProfile(s Scanner, files []File) *Profile {
+	type samples struct {
+		confidence []float64
+		signals    map[string][]float64
+	}
+	byLang := map[Language]*samples{}
+	for i, r := range ScanAll(s, files) {
+		if r.Err != nil {
+			continue
+		}
+		lang := DetectLanguage(files[i].Path, files[i].Content)
+		g := byLang[lang]
+		if g == nil {
+			g = &samples{signals: map[string][]float64{}}
+			byLang[lang] = g
+		}
+		g.confidence = append(g.confidence, r.Confidence)
+		for _, sig := range r.Signals {
+			g.signals[sig.Name] = append(g.signals[sig.Name], sig.Value)
+		}
+	}
+
+	p := &Profile{Fingerprint: Fingerprint(s), Groups: map[Language]*ProfileGroup{}}
+	for lang, g := range byLang {
+		pg := &ProfileGroup{Files: len(g.confidence), Confidence: statOf(g.confidence), Signals: map[string]Stat{}}
+		for name, v := range g.signals {
+			pg.Signals[name] = statOf(v)
+		}
+		p.Groups[lang] = pg
+	}
+	return p
+}
+
+// LoadProfile reads a Profile saved with WriteFile.
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("aiscan: read profile: %w", err)
+	}
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("aiscan: parse profile %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// WriteFile saves p as indented JSON, atomically.
+func (p *Profile) WriteFile(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("aiscan: write profile: %w", err)
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("aiscan: write profile: %w", err)
+	}
+	_, werr := tmp.Write(append(data, '\n'))
+	cerr := tmp.Close()
+	if err := errors.Join(werr, cerr); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("aiscan: write profile: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("aiscan: write profile: %w", err)
+	}
+	return nil
+}
+
+// digest is a short hash of the profile's content, for fingerprints.
+func (p *Profile) digest() string {
+	langs := make([]string, 0, len(p.Groups))
+	for l := range p.Groups {
+		langs = append(langs, string(l))
+	}
+	sort.Strings(langs)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", p.Fingerprint, p.Commit)
+	for _, l := range langs {
+		data, _ := json.Marshal(p.Groups[Language(l)]) // map keys are sorted
+		fmt.Fprintf(h, "%s\x00%s\x00", l, data)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// RelativeScanner judges files against a repository baseline instead of
+// absolute values.  It runs Inner, converts the confidence to a z-score
+// against the Profile group for the file's language, and reports
+//
+//	confidence = Φ(z − Threshold)
+//
+// (Φ being the standard normal CDF), so a file is flagged exactly when it
+// sits Threshold or more deviations above the repo's norm.  Files whose
+// language has fewer than MinFiles profiled files keep Inner's absolute
+// verdict.
+type RelativeScanner struct {
+	Inner   Scanner
+	Profile *Profile
+	// Threshold is the z-score at which a file is flagged.  Zero means 2.
+	Threshold float64
+	// MinFiles is the smallest profile group used.  Zero means 5.
+	MinFiles int
+}
+
+// relativeMinStdDev floors a group's spread so a repo of near-identical
+// files does not turn every small difference into a large z-score.
+const relativeMinStdDev = 0.05
+
+func (r *RelativeScanner) Scan(path string, content []byte) (bool, float64, error) {
+	res, err := r.ScanDetailed(path, content)
+	return res.LikelyAI, res.Confidence, err
+}
+
+// ScanDetailed reports a "baseline" signal holding the relative confidence,
+// followed by Inner's signals with each Value replaced by how far it sits
+// above the baseline (Φ(z) mapped from [0.5,1] to [0,1]; 0 at or below the
+// mean).  Signals the profile has no data for keep their raw values.
+func (r *RelativeScanner) ScanDetailed(path string, content []byte) (Result, error) {
+	res, err := ScanDetailed(r.Inner, path, content)
+	if err != nil {
+		return res, err
+	}
+	return r.relative(path, content, res), nil
+}
+
+// ScanBatch scans the batch with Inner (in one batch if Inner supports it)
+// and rescales each result.
+func (r *RelativeScanner) ScanBatch(files []File) ([]BatchResult, error) {
+	return r.ScanBatchContext(context.Background(), files)
+}
+
+// ScanBatchContext is ScanBatch with ctx passed on to Inner.
+func (r *RelativeScanner) ScanBatchContext(ctx context.Context, files []File) ([]BatchResult, error) {
+	results := ScanAllContext(ctx, r.Inner, files)
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Result = r.relative(files[i].Path, files[i].Content, results[i].Result)
+		}
+	}
+	return results, nil
+}
+
+func (r *RelativeScanner) relative(path string, content []byte, res Result) Result {
+	minFiles := r.MinFiles
+	if minFiles <= 0 {
+		minFiles = 5
+	}
+	threshold := r.Threshold
+	if threshold == 0 {
+		threshold = 2
+	}
+	if r.Profile == nil {
+		return res
+	}
+	g := r.Profile.Groups[DetectLanguage(path, content)]
+	if g == nil || g.Files < minFiles {
+		return res
+	}
+
+	z := zScore(res.Confidence, g.Confidence)
+	conf := normalCDF(z - threshold)
+	signals := []Signal{{Name: "baseline", Value: conf, Weight: 1}}
+	var wsum float64
+	for _, s := range res.Signals {
+		if st, ok := g.Signals[s.Name]; ok {
+			s.Value = math.Max(0, 2*normalCDF(zScore(s.Value, st))-1)
+		}
+		wsum += s.Weight
+		signals = append(signals, s)
+	}
+	for i := 1; i < len(signals); i++ {
+		if wsum > 0 {
+			signals[i].Contribution = signals[i].Value * signals[i].Weight / wsum
+		}
+	}
+	signals[0].Contribution = conf
+	return Result{LikelyAI: conf >= 0.5, Confidence: conf, Signals: signals}
+}
+
+// Fingerprint covers the threshold, the profile and Inner.
+func (r *RelativeScanner) Fingerprint() string {
+	return r.fingerprint(Fingerprint(r.Inner))
+}
+
+// FingerprintPath is Fingerprint with Inner's FingerprintPath.
+func (r *RelativeScanner) FingerprintPath(path string) string {
+	return r.fingerprint(FingerprintPath(r.Inner, path))
+}
+
+func (r *RelativeScanner) fingerprint(inner string) string {
+	digest := "none"
+	if r.Profile != nil {
+		digest = r.Profile.digest()
+	}
+	return fmt.Sprintf("relative/%g/%d/%s[%s]", r.Threshold, r.MinFiles, digest, inner)
+}
+
+// PathSensitive reports whether Inner is.
+func (r *RelativeScanner) PathSensitive() bool { return IsPathSensitive(r.Inner) }
+
+// Close closes Inner.
+func (r *RelativeScanner) Close() error { return Close(r.Inner) }
+
+func zScore(x float64, s Stat) float64 {
+	return (x - s.Mean) / math.Max(s.StdDev, relativeMinStdDev)
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * (1 + math.Erf(z/math.Sqrt2))
+}
+
+// statOf returns the mean and (population) standard deviation of v.
+func statOf(v []float64) Stat {
+	if len(v) == 0 {
+		return Stat{}
+	}
+	var sum, sq float64
+	for _, x := range v {
+		sum += x
+	}
+	mean := sum / float64(len(v))
+	for _, x := range v {
+		sq += (x - mean) * (x - mean)
+	}
+	return Stat{Mean: mean, StdDev: math.Sqrt(sq / float64(len(v)))}
+}
@@ -0,0 +1,209 @@
+package aiscan
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backends are registered by name, each with a factory taking a typed
+// config struct.  The registry decodes the struct from environment
+// variables named by its fields' tags:
+//
+//	type myConfig struct {
+//		Model string        `env:"MY_DETECTOR_MODEL" help:"model file"`
+//		Limit int           `env:"MY_DETECTOR_LIMIT" default:"10"`
+//		Wait  time.Duration `env:"MY_DETECTOR_WAIT" default:"5s"`
+//	}
+//
+//	func init() {
+//		aiscan.Register("mydetector", func(cfg myConfig, rc aiscan.RepoConfig) (aiscan.Scanner, error) {
+//			...
+//		})
+//	}
+//
+// Supported field types are string (trimmed), []string (split on
+// whitespace), int, float64, bool and time.Duration.  Fields without an
+// env tag are left at their zero value.  A module that registers a backend
+// this way only needs to be linked into a custom build (a blank import in
+// a copy of check_ai_key's main package) for AI_SCAN_BACKEND to select it.
+
+// BackendInfo describes a registered backend.
+type BackendInfo struct {
+	Name string
+	// Keys are the environment variables the backend's config reads, in
+	// field order.
+	Keys []ConfigKey
+}
+
+// ConfigKey is one environment variable of a backend's config.
+type ConfigKey struct {
+	Env     string
+	Type    string // "string", "[]string", "int", "float64", "bool" or "duration"
+	Default string
+	Help    string
+}
+
+type registration struct {
+	info  BackendInfo
+	build func(rc RepoConfig) (Scanner, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*registration{}
+)
+
+// Register makes a backend available to FromEnv and BackendFromEnv under
+// name (matched case-insensitively).  C must be a struct type whose env
+// tags use the supported field types.  Register is meant to be called from
+// init functions; it panics if name is already registered or C is not a
+// valid config type.
+func Register[C any](name string, factory func(cfg C, rc RepoConfig) (Scanner, error)) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	t := reflect.TypeFor[C]()
+	keys, err := configKeys(t)
+	if err != nil {
+		panic(fmt.Sprintf("aiscan: Register(%q): %v", name, err))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" {
+		panic("aiscan: Register with empty name")
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("aiscan: Register called twice for backend %q", name))
+	}
+	registry[name] = &registration{
+		info: BackendInfo{Name: name, Keys: keys},
+		build: func(rc RepoConfig) (Scanner, error) {
+			var cfg C
+			if err := decodeEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+				return nil, err
+			}
+			return factory(cfg, rc)
+		},
+	}
+}
+
+// Backends lists the registered backends sorted by name.
+func Backends() []BackendInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	infos := make([]BackendInfo, 0, len(registry))
+	for _, r := range registry {
+		infos = append(infos, r.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// errUnknownBackend is wrapped by the error newBackend returns for a name
+// nothing registered, so callers can say where the name came from.
+var errUnknownBackend = errors.New("unknown backend")
+
+// newBackend builds a registered backend by name.
+func newBackend(name string, rc RepoConfig) (Scanner, error) {
+	registryMu.RLock()
+	r := registry[name]
+	registryMu.RUnlock()
+	if r == nil {
+		names := make([]string, 0)
+		for _, b := range Backends() {
+			names = append(names, b.Name)
+		}
+		return nil, fmt.Errorf("%w %q (valid: %s)", errUnknownBackend, name, strings.Join(names, ", "))
+	}
+	return r.build(rc)
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// configKeys lists the env-tagged fields of the struct type t.
+func configKeys(t reflect.Type) ([]ConfigKey, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config type %s is not a struct", t)
+	}
+	var keys []ConfigKey
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		env := f.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		if !f.IsExported() {
+			return nil, fmt.Errorf("config field %s is not exported", f.Name)
+		}
+		var typ string
+		switch kind := f.Type.Kind(); {
+		case f.Type == durationType:
+			typ = "duration"
+		case kind == reflect.String, kind == reflect.Int, kind == reflect.Float64, kind == reflect.Bool:
+			typ = kind.String()
+		case kind == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			typ = "[]string"
+		default:
+			return nil, fmt.Errorf("config field %s has unsupported type %s", f.Name, f.Type)
+		}
+		keys = append(keys, ConfigKey{Env: env, Type: typ, Default: f.Tag.Get("default"), Help: f.Tag.Get("help")})
+	}
+	return keys, nil
+}
+
+// decodeEnv fills the env-tagged fields of the struct v from the
+// environment, falling back to their default tags.
+func decodeEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		env := f.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		raw := strings.TrimSpace(os.Getenv(env))
+		if raw == "" {
+			raw = f.Tag.Get("default")
+		}
+		if raw == "" {
+			continue
+		}
+		field := v.Field(i)
+		switch {
+		case f.Type == durationType:
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q (want a Go duration such as 20s)", env, raw)
+			}
+			field.SetInt(int64(d))
+		case f.Type.Kind() == reflect.String:
+			field.SetString(raw)
+		case f.Type.Kind() == reflect.Slice:
+			field.Set(reflect.ValueOf(strings.Fields(raw)).Convert(f.Type))
+		case f.Type.Kind() == reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", env, raw)
+			}
+			field.SetInt(int64(n))
+		case f.Type.Kind() == reflect.Float64:
+			x, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", env, raw)
+			}
+			field.SetFloat(x)
+		case f.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", env, raw)
+			}
+			field.SetBool(b)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package aiscan
+
+// Result is the detailed outcome of a scan: the same verdict Scan returns,
+// plus the evidence behind it.
+type Result struct {
+	LikelyAI   bool    `json:"likely_ai"`
+	Confidence float64 `json:"confidence"`
+	// Signals lists every signal the backend evaluated, fired or not, in
+	// the order the backend computed them.
+	Signals []Signal `json:"signals,omitempty"`
+}
+
+// Signal is one scored feature of a file.
+type Signal struct {
+	// Name is a short stable identifier such as "phrase-density".
+	Name string `json:"name"`
+	// Value is the raw signal strength in [0,1].
+	Value float64 `json:"value"`
+	// Weight is the signal's weight in the backend's combined score.
+	Weight float64 `json:"weight"`
+	// Contribution is the signal's share of the combined (pre-squash)
+	// score, i.e. Value*Weight divided by the sum of all weights.
+	Contribution float64 `json:"contribution"`
+	// Matches are the concrete hits that produced Value, if the signal
+	// is phrase-based.
+	Matches []Match `json:"matches,omitempty"`
+}
+
+// Fired reports whether the signal contributed anything to the score.
+func (s Signal) Fired() bool { return s.Value > 0 }
+
+// Match is a single phrase hit inside the scanned content.
+type Match struct {
+	// Line is the 1-based line number the matching sentence starts on.
+	Line   int    `json:"line"`
+	Phrase string `json:"phrase"`
+}
+
+// DetailedScanner is implemented by Scanners that can explain their
+// verdict.  It is optional: use ScanDetailed to get a Result from any
+// Scanner.
+type DetailedScanner interface {
+	Scanner
+	ScanDetailed(path string, content []byte) (Result, error)
+}
+
+// ScanDetailed runs s over content and returns a Result.  Scanners that do
+// not implement DetailedScanner yield a Result with no Signals.
+func ScanDetailed(s Scanner, path string, content []byte) (Result, error) {
+	if d, ok := s.(DetailedScanner); ok {
+		return d.ScanDetailed(path, content)
+	}
+	likelyAI, confidence, err := s.Scan(path, content)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{LikelyAI: likelyAI, Confidence: confidence}, nil
+}
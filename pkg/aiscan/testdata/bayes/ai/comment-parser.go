@@ -0,0 +1,15 @@
+package parse
+
+// skipBOM removes the UTF-8 byte order mark from the beginning of the
+// provided byte slice if it is present. This function ensures that the
+// input data is properly formatted for subsequent processing steps.
+func skipBOM(b []byte) []byte { return b }
+
+// rangeOrSub determines whether the given expression should be interpreted
+// as a range or a subtraction operation. This function leverages heuristics
+// to provide robust and accurate parsing across a variety of input formats.
+func rangeOrSub() {}
+
+// dedupe removes duplicate elements from the list, ensuring that each
+// element appears only once in the resulting collection.
+func dedupe() {}
@@ -0,0 +1,160 @@
+package keyguard
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CI event kinds, as reported by CIContext.Event.
+const (
+	EventPullRequest = "pull_request" // pull request / merge request pipeline
+	EventPush        = "push"         // anything else
+)
+
+// CIContext describes the CI run the check is part of.
+type CIContext interface {
+	// Provider names the CI system: "github", "forgejo", "gitlab" or
+	// "local".
+	Provider() string
+	// Event is EventPullRequest or EventPush.
+	Event() string
+	// BaseRef is the branch a pull request targets, "" for a push.
+	BaseRef() string
+	// BaseSHA is the diff base the provider computed itself, if it does
+	// (GitLab); "" leaves it to the merge base of HEAD and BaseRef.
+	BaseSHA() string
+	// HeadSHA is the commit the run is for, "" when unknown.
+	HeadSHA() string
+	// PRNumber is the pull request number, 0 for a push.
+	PRNumber() int
+	// PullRequest returns the pull request with its description, or nil
+	// when the run is not for one.
+	PullRequest() (*PullRequest, error)
+}
+
+// ciDetectors are tried in order by DetectCI.  Forgejo and Gitea set the
+// GITHUB_* variables for compatibility, so they are tried before GitHub.
+var ciDetectors = []func(getenv func(string) string) CIContext{
+	detectForgejo,
+	detectGitHub,
+	detectGitLab,
+}
+
+// DetectCI identifies the CI system from its environment, read through
+// getenv (os.Getenv, or a map lookup for fixtures).  Outside a known CI
+// system it returns a local context: a push of HEAD.
+func DetectCI(getenv func(string) string) CIContext {
+	env := func(key string) string { return strings.TrimSpace(getenv(key)) }
+	for _, detect := range ciDetectors {
+		if ci := detect(env); ci != nil {
+			return ci
+		}
+	}
+	return &ciRun{provider: "local", event: EventPush}
+}
+
+// ciRun is the CIContext every detector fills in.
+type ciRun struct {
+	provider  string
+	event     string
+	baseRef   string
+	baseSHA   string
+	headSHA   string
+	number    int
+	eventPath string       // GitHub-style webhook payload, if any
+	pr        *PullRequest // pull request built from the environment, if any
+}
+
+func (c *ciRun) Provider() string { return c.provider }
+func (c *ciRun) Event() string    { return c.event }
+func (c *ciRun) BaseRef() string  { return c.baseRef }
+func (c *ciRun) BaseSHA() string  { return c.baseSHA }
+func (c *ciRun) HeadSHA() string  { return c.headSHA }
+func (c *ciRun) PRNumber() int    { return c.number }
+
+func (c *ciRun) PullRequest() (*PullRequest, error) {
+	if c.pr != nil || c.eventPath == "" {
+		return c.pr, nil
+	}
+	return ReadPullRequestEvent(c.eventPath)
+}
+
+// detectGitHub reads the GitHub Actions environment.  GITHUB_EVENT_NAME
+// alone is enough, so a run can be replayed by hand.
+func detectGitHub(env func(string) string) CIContext {
+	if env("GITHUB_ACTIONS") != "true" && env("GITHUB_EVENT_NAME") == "" {
+		return nil
+	}
+	return githubStyle("github", env)
+}
+
+// detectForgejo reads the Forgejo (or Gitea) Actions environment.  Each
+// variable may come with a FORGEJO_ or GITEA_ prefix, falling back to the
+// GITHUB_ one both runners also set.
+func detectForgejo(env func(string) string) CIContext {
+	if env("FORGEJO_ACTIONS") != "true" && env("GITEA_ACTIONS") != "true" {
+		return nil
+	}
+	return githubStyle("forgejo", func(key string) string {
+		name := strings.TrimPrefix(key, "GITHUB_")
+		for _, prefix := range []string{"FORGEJO_", "GITEA_"} {
+			if v := env(prefix + name); v != "" {
+				return v
+			}
+		}
+		return env(key)
+	})
+}
+
+// githubStyle reads the GITHUB_* variables GitHub Actions defines and
+// Forgejo and Gitea mirror.
+func githubStyle(provider string, env func(string) string) CIContext {
+	c := &ciRun{
+		provider:  provider,
+		event:     EventPush,
+		headSHA:   env("GITHUB_SHA"),
+		eventPath: env("GITHUB_EVENT_PATH"),
+	}
+	switch env("GITHUB_EVENT_NAME") {
+	case "pull_request", "pull_request_target":
+		c.event = EventPullRequest
+		c.baseRef = env("GITHUB_BASE_REF")
+		// refs/pull/<n>/merge (GitHub) or refs/pull/<n>/head (Forgejo).
+		if rest, ok := strings.CutPrefix(env("GITHUB_REF"), "refs/pull/"); ok {
+			n, _, _ := strings.Cut(rest, "/")
+			c.number, _ = strconv.Atoi(n)
+		}
+	}
+	return c
+}
+
+// detectGitLab reads the GitLab CI environment.  Merge request pipelines
+// carry the diff base, and the title and description (which GitLab
+// truncates to 2700 characters), in the environment itself.
+func detectGitLab(env func(string) string) CIContext {
+	if env("GITLAB_CI") != "true" {
+		return nil
+	}
+	c := &ciRun{provider: "gitlab", event: EventPush, headSHA: env("CI_COMMIT_SHA")}
+	iid := env("CI_MERGE_REQUEST_IID")
+	if iid == "" {
+		return c
+	}
+	c.event = EventPullRequest
+	c.baseRef = env("CI_MERGE_REQUEST_TARGET_BRANCH_NAME")
+	c.baseSHA = env("CI_MERGE_REQUEST_DIFF_BASE_SHA")
+	c.number, _ = strconv.Atoi(iid)
+	c.pr = &PullRequest{
+		Number:  c.number,
+		Title:   env("CI_MERGE_REQUEST_TITLE"),
+		Body:    env("CI_MERGE_REQUEST_DESCRIPTION"),
+		HeadSHA: c.headSHA,
+		BaseSHA: c.baseSHA,
+		BaseRef: c.baseRef,
+		Author:  env("GITLAB_USER_LOGIN"),
+	}
+	if sha := env("CI_MERGE_REQUEST_SOURCE_BRANCH_SHA"); sha != "" {
+		c.pr.HeadSHA = sha // merged results pipelines run on a merge commit
+	}
+	return c
+}
@@ -0,0 +1,155 @@
+package keyguard
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ciFields is what a CIContext reports, for comparison.
+type ciFields struct {
+	Provider, Event, BaseRef, BaseSHA, HeadSHA string
+	PRNumber                                   int
+	PR                                         *PullRequest
+}
+
+func TestDetectCI(t *testing.T) {
+	event := filepath.Join("testdata", "events", "pull_request.json")
+	eventPR := &PullRequest{
+		Number:  42,
+		Title:   "Add retry to the uploader",
+		Body:    "Retries uploads on 5xx.\n\nAIKEY-abcdefgh234567",
+		HeadSHA: "1111111111111111111111111111111111111111",
+		BaseSHA: "2222222222222222222222222222222222222222",
+		BaseRef: "main",
+		Author:  "octocat",
+	}
+	githubPR := map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_EVENT_NAME": "pull_request",
+		"GITHUB_EVENT_PATH": event,
+		"GITHUB_BASE_REF":   "main",
+		"GITHUB_REF":        "refs/pull/42/merge",
+		"GITHUB_SHA":        "aaaa",
+	}
+	gitlabMR := map[string]string{
+		"GITLAB_CI":                           "true",
+		"CI_COMMIT_SHA":                       "cccc",
+		"CI_MERGE_REQUEST_IID":                "9",
+		"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "main",
+		"CI_MERGE_REQUEST_DIFF_BASE_SHA":      "dddd",
+		"CI_MERGE_REQUEST_TITLE":              "Add a thing",
+		"CI_MERGE_REQUEST_DESCRIPTION":        "  AIKEY-abcdefgh234567\n",
+		"GITLAB_USER_LOGIN":                   "tanuki",
+	}
+	// merge returns the union of envs, later ones winning.
+	merge := func(envs ...map[string]string) map[string]string {
+		out := map[string]string{}
+		for _, env := range envs {
+			for k, v := range env {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want ciFields
+	}{
+		{"local", nil, ciFields{Provider: "local", Event: EventPush}},
+		{
+			"unrelated CI",
+			map[string]string{"CI": "true", "GITLAB_CI": "false"},
+			ciFields{Provider: "local", Event: EventPush},
+		},
+		{
+			"github push",
+			map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_EVENT_NAME": "push", "GITHUB_SHA": "aaaa", "GITHUB_REF": "refs/heads/main"},
+			ciFields{Provider: "github", Event: EventPush, HeadSHA: "aaaa"},
+		},
+		{
+			"github pull request",
+			githubPR,
+			ciFields{Provider: "github", Event: EventPullRequest, BaseRef: "main", HeadSHA: "aaaa", PRNumber: 42, PR: eventPR},
+		},
+		{
+			"github pull_request_target, replayed without GITHUB_ACTIONS",
+			map[string]string{"GITHUB_EVENT_NAME": "pull_request_target", "GITHUB_BASE_REF": "develop", "GITHUB_REF": "refs/pull/7/merge"},
+			ciFields{Provider: "github", Event: EventPullRequest, BaseRef: "develop", PRNumber: 7},
+		},
+		{
+			"forgejo pull request",
+			map[string]string{
+				"FORGEJO_ACTIONS":    "true",
+				"FORGEJO_EVENT_NAME": "pull_request",
+				"FORGEJO_BASE_REF":   "main",
+				"FORGEJO_REF":        "refs/pull/5/head",
+				"FORGEJO_SHA":        "bbbb",
+			},
+			ciFields{Provider: "forgejo", Event: EventPullRequest, BaseRef: "main", HeadSHA: "bbbb", PRNumber: 5},
+		},
+		{
+			"gitea falls back to GITHUB_*",
+			map[string]string{
+				"GITEA_ACTIONS":     "true",
+				"GITHUB_EVENT_NAME": "pull_request",
+				"GITHUB_BASE_REF":   "main",
+				"GITHUB_REF":        "refs/pull/6/head",
+				"GITEA_SHA":         "bbbb",
+				"GITHUB_SHA":        "aaaa",
+			},
+			ciFields{Provider: "forgejo", Event: EventPullRequest, BaseRef: "main", HeadSHA: "bbbb", PRNumber: 6},
+		},
+		{
+			"gitlab push",
+			map[string]string{"GITLAB_CI": "true", "CI_COMMIT_SHA": "cccc"},
+			ciFields{Provider: "gitlab", Event: EventPush, HeadSHA: "cccc"},
+		},
+		{
+			"gitlab merge request",
+			gitlabMR,
+			ciFields{
+				Provider: "gitlab", Event: EventPullRequest, BaseRef: "main", BaseSHA: "dddd", HeadSHA: "cccc", PRNumber: 9,
+				PR: &PullRequest{Number: 9, Title: "Add a thing", Body: "AIKEY-abcdefgh234567", HeadSHA: "cccc", BaseSHA: "dddd", BaseRef: "main", Author: "tanuki"},
+			},
+		},
+		{
+			"gitlab merged results pipeline",
+			merge(gitlabMR, map[string]string{"CI_MERGE_REQUEST_SOURCE_BRANCH_SHA": "eeee"}),
+			ciFields{
+				Provider: "gitlab", Event: EventPullRequest, BaseRef: "main", BaseSHA: "dddd", HeadSHA: "cccc", PRNumber: 9,
+				PR: &PullRequest{Number: 9, Title: "Add a thing", Body: "AIKEY-abcdefgh234567", HeadSHA: "eeee", BaseSHA: "dddd", BaseRef: "main", Author: "tanuki"},
+			},
+		},
+		{
+			// Forgejo runners also set GITHUB_ACTIONS.
+			"forgejo over github",
+			merge(githubPR, map[string]string{"FORGEJO_ACTIONS": "true", "FORGEJO_REF": "refs/pull/5/head"}),
+			ciFields{Provider: "forgejo", Event: EventPullRequest, BaseRef: "main", HeadSHA: "aaaa", PRNumber: 5, PR: eventPR},
+		},
+		{
+			"github over gitlab",
+			merge(gitlabMR, githubPR),
+			ciFields{Provider: "github", Event: EventPullRequest, BaseRef: "main", HeadSHA: "aaaa", PRNumber: 42, PR: eventPR},
+		},
+		{
+			"forgejo over gitlab",
+			merge(gitlabMR, map[string]string{"GITEA_ACTIONS": "true", "GITEA_EVENT_NAME": "push", "GITEA_SHA": "bbbb"}),
+			ciFields{Provider: "forgejo", Event: EventPush, HeadSHA: "bbbb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci := DetectCI(envMap(tt.env))
+			pr, err := ci.PullRequest()
+			if err != nil {
+				t.Fatalf("PullRequest: %v", err)
+			}
+			got := ciFields{ci.Provider(), ci.Event(), ci.BaseRef(), ci.BaseSHA(), ci.HeadSHA(), ci.PRNumber(), pr}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectCI = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package keyguard
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileKind classifies a submitted file by what its content is.
+type FileKind int
+
+const (
+	// FileRegular is an ordinary file; its content is what was submitted.
+	FileRegular FileKind = iota
+	// FileSymlink is a symbolic link; its content is the link target.
+	FileSymlink
+	// FileLFS is a Git LFS pointer; the real content lives outside git.
+	FileLFS
+)
+
+func (k FileKind) String() string {
+	switch k {
+	case FileSymlink:
+		return "symbolic link"
+	case FileLFS:
+		return "Git LFS pointer"
+	}
+	return "regular file"
+}
+
+// Content reads the files of a submission.
+type Content interface {
+	// ReadFile returns the content and kind of rel (relative to the repo
+	// root).  The error wraps ErrNotFound when the submission has no such
+	// file.
+	ReadFile(rel string) ([]byte, FileKind, error)
+}
+
+// WorkTreeContent reads files from the working tree at Root, which may
+// differ from the commit under check.  Symbolic links are not followed.
+type WorkTreeContent struct {
+	Root string
+}
+
+func (w WorkTreeContent) ReadFile(rel string) ([]byte, FileKind, error) {
+	full := filepath.Join(w.Root, filepath.FromSlash(rel))
+	fi, err := os.Lstat(full)
+	if os.IsNotExist(err) {
+		return nil, 0, fmt.Errorf("keyguard: %s: %w", rel, ErrNotFound)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("keyguard: stat %s: %w", rel, err)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(full)
+		if err != nil {
+			return nil, 0, fmt.Errorf("keyguard: readlink %s: %w", rel, err)
+		}
+		return []byte(target), FileSymlink, nil
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return nil, 0, fmt.Errorf("keyguard: read %s: %w", rel, err)
+	}
+	return data, kindOf(data), nil
+}
+
+// TreeContent reads files from Commit's tree: exactly what was committed,
+// whatever the working tree holds.
+type TreeContent struct {
+	Repo   GitRepo
+	Commit string
+}
+
+func (t TreeContent) ReadFile(rel string) ([]byte, FileKind, error) {
+	mode, err := t.Repo.Mode(t.Commit, rel)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch mode {
+	case "100644", "100755", "120000":
+	default:
+		// A directory or submodule: no file of that name was submitted.
+		return nil, 0, fmt.Errorf("keyguard: %s:%s is not a file: %w", t.Commit, rel, ErrNotFound)
+	}
+	data, err := t.Repo.ReadFile(t.Commit, rel)
+	if err != nil {
+		return nil, 0, err
+	}
+	if mode == "120000" {
+		return data, FileSymlink, nil
+	}
+	return data, kindOf(data), nil
+}
+
+// CachedContent is Src with every answer remembered, so a check that
+// reads a file several times (key, placement, tokens, AI scan) reads it
+// from git once.  It is not safe for concurrent use.
+type CachedContent struct {
+	Src   Content
+	files map[string]cachedFile
+}
+
+type cachedFile struct {
+	data []byte
+	kind FileKind
+	err  error
+}
+
+func (c *CachedContent) ReadFile(rel string) ([]byte, FileKind, error) {
+	if f, ok := c.files[rel]; ok {
+		return f.data, f.kind, f.err
+	}
+	data, kind, err := c.Src.ReadFile(rel)
+	if c.files == nil {
+		c.files = map[string]cachedFile{}
+	}
+	c.files[rel] = cachedFile{data, kind, err}
+	return data, kind, err
+}
+
+// lfsPointerPrefix starts every Git LFS pointer file.
+var lfsPointerPrefix = []byte("version https://git-lfs.github.com/spec/v1\n")
+
+// IsLFSPointer reports whether data is a Git LFS pointer: a short file
+// starting with the spec version line and naming an oid.
+func IsLFSPointer(data []byte) bool {
+	return len(data) < 1024 && bytes.HasPrefix(data, lfsPointerPrefix) && bytes.Contains(data, []byte("\noid sha256:"))
+}
+
+func kindOf(data []byte) FileKind {
+	if IsLFSPointer(data) {
+		return FileLFS
+	}
+	return FileRegular
+}
+
+// ContentFromEnv returns the Content the check reads, chosen by
+// AI_KEY_CONTENT:
+//
+//	tree      the tree of head, the resolved HEAD commit (default in CI)
+//	worktree  the working tree (default for local runs)
+func ContentFromEnv(repoRoot string, repo GitRepo, head string, ci CIContext) (Content, string, error) {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("AI_KEY_CONTENT")))
+	if mode == "" {
+		mode = "tree"
+		if ci.Provider() == "local" {
+			mode = "worktree"
+		}
+	}
+	switch mode {
+	case "tree":
+		return TreeContent{Repo: repo, Commit: head}, mode, nil
+	case "worktree":
+		return WorkTreeContent{Root: repoRoot}, mode, nil
+	}
+	return nil, "", fmt.Errorf("keyguard: unknown AI_KEY_CONTENT %q (valid: tree, worktree)", mode)
+}
@@ -0,0 +1,35 @@
+package keyguard
+
+import (
+	"errors"
+	"testing"
+)
+
+// countingContent counts the reads that reach Src.
+type countingContent struct {
+	Src   Content
+	reads map[string]int
+}
+
+func (c *countingContent) ReadFile(rel string) ([]byte, FileKind, error) {
+	c.reads[rel]++
+	return c.Src.ReadFile(rel)
+}
+
+func TestCachedContent(t *testing.T) {
+	r := NewFakeRepo()
+	head := r.Commit("init", map[string]string{"a.go": "package a\n"})
+	counting := &countingContent{Src: TreeContent{Repo: r, Commit: head}, reads: map[string]int{}}
+	c := &CachedContent{Src: counting}
+	for i := 0; i < 3; i++ {
+		if data, kind, err := c.ReadFile("a.go"); string(data) != "package a\n" || kind != FileRegular || err != nil {
+			t.Fatalf("ReadFile(a.go) = %q, %v, %v", data, kind, err)
+		}
+		if _, _, err := c.ReadFile("gone.go"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ReadFile(gone.go) error = %v, want ErrNotFound", err)
+		}
+	}
+	if counting.reads["a.go"] != 1 || counting.reads["gone.go"] != 1 {
+		t.Errorf("reads = %v, want one per path", counting.reads)
+	}
+}
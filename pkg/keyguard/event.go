@@ -0,0 +1,83 @@
+package keyguard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PullRequest is the part of a CI pull request event the key check uses.
+type PullRequest struct {
+	Number  int
+	Title   string
+	Body    string
+	HeadSHA string
+	BaseSHA string
+	BaseRef string
+	Author  string
+}
+
+// githubEvent mirrors the fields of a GitHub webhook payload that
+// PullRequest needs.  pull_request and pull_request_target events carry
+// the same "pull_request" object.
+type githubEvent struct {
+	PullRequest *struct {
+		Number int     `json:"number"`
+		Title  string  `json:"title"`
+		Body   *string `json:"body"` // null when the description is empty
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			SHA string `json:"sha"`
+			Ref string `json:"ref"`
+		} `json:"base"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+}
+
+// ReadPullRequestEvent parses the GitHub (or Forgejo) event payload at
+// path.  It returns (nil, nil) when the event is not about a pull request
+// (e.g. a push).
+func ReadPullRequestEvent(path string) (*PullRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keyguard: read event payload: %w", err)
+	}
+	var ev githubEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return nil, fmt.Errorf("keyguard: parse event payload %s: %w", path, err)
+	}
+	p := ev.PullRequest
+	if p == nil {
+		return nil, nil
+	}
+	pr := &PullRequest{
+		Number:  p.Number,
+		Title:   p.Title,
+		HeadSHA: p.Head.SHA,
+		BaseSHA: p.Base.SHA,
+		BaseRef: p.Base.Ref,
+		Author:  p.User.Login,
+	}
+	if p.Body != nil {
+		pr.Body = *p.Body
+	}
+	return pr, nil
+}
+
+// PullRequestFromEnv returns the pull request of the CI run DetectCI finds
+// in the process environment: from the webhook payload on GitHub and
+// Forgejo, from CI_MERGE_REQUEST_* on GitLab.  It returns (nil, nil)
+// outside CI and for events other than pull requests.
+func PullRequestFromEnv() (*PullRequest, error) {
+	return DetectCI(os.Getenv).PullRequest()
+}
+
+// HasKey reports whether the pull request description carries key.
+func (pr *PullRequest) HasKey(key string) bool {
+	return pr != nil && key != "" && strings.Contains(pr.Body, key)
+}
@@ -0,0 +1,74 @@
+package keyguard
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPullRequestEvent(t *testing.T) {
+	tests := []struct {
+		file    string
+		want    *PullRequest
+		wantErr bool
+	}{
+		{
+			file: "pull_request.json",
+			want: &PullRequest{
+				Number:  42,
+				Title:   "Add retry to the uploader",
+				Body:    "Retries uploads on 5xx.\n\nAIKEY-abcdefgh234567",
+				HeadSHA: "1111111111111111111111111111111111111111",
+				BaseSHA: "2222222222222222222222222222222222222222",
+				BaseRef: "main",
+				Author:  "octocat",
+			},
+		},
+		{
+			// A null body is an empty description.
+			file: "pull_request_target.json",
+			want: &PullRequest{
+				Number:  7,
+				Title:   "Fix typo",
+				HeadSHA: "3333333333333333333333333333333333333333",
+				BaseSHA: "4444444444444444444444444444444444444444",
+				BaseRef: "develop",
+				Author:  "forker",
+			},
+		},
+		{file: "push.json", want: nil},
+		{file: "truncated.json", wantErr: true},
+		{file: "missing.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := ReadPullRequestEvent(filepath.Join("testdata", "events", tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullRequestHasKey(t *testing.T) {
+	pr, err := ReadPullRequestEvent(filepath.Join("testdata", "events", "pull_request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{
+		"AIKEY-abcdefgh234567": true,
+		"AIKEY-zzzzzzzz234567": false,
+		"":                     false,
+	} {
+		if got := pr.HasKey(key); got != want {
+			t.Errorf("HasKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+	var none *PullRequest
+	if none.HasKey("AIKEY-abcdefgh234567") {
+		t.Errorf("nil pull request has a key")
+	}
+}
@@ -0,0 +1,150 @@
+package keyguard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ExecRepo is a GitRepo that runs the git binary in Root.
+type ExecRepo struct {
+	Root string
+}
+
+func (r *ExecRepo) Resolve(rev string) (string, error) {
+	sha, err := gitOutput(r.Root, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		if isExitError(err) {
+			return "", fmt.Errorf("keyguard: resolve %s: %w", rev, ErrNotFound)
+		}
+		return "", fmt.Errorf("keyguard: git rev-parse %s: %w", rev, err)
+	}
+	return sha, nil
+}
+
+func (r *ExecRepo) MergeBase(a, b string) (string, error) {
+	sha, err := gitOutput(r.Root, "merge-base", a, b)
+	if err != nil {
+		// Status 1 means unrelated histories; a bad revision is 128.
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.ExitCode() == 1 {
+			return "", fmt.Errorf("keyguard: merge-base %s %s: %w", a, b, ErrNotFound)
+		}
+		return "", fmt.Errorf("keyguard: git merge-base %s %s: %w", a, b, err)
+	}
+	return sha, nil
+}
+
+// ReadFile runs one git process.  An unknown commit or path, or a path
+// naming a directory, fails with ErrNotFound.
+func (r *ExecRepo) ReadFile(commit, path string) ([]byte, error) {
+	cmd := exec.Command("git", "cat-file", "blob", commit+":"+path)
+	cmd.Dir = r.Root
+	data, err := cmd.Output()
+	if err != nil {
+		if isExitError(err) {
+			return nil, fmt.Errorf("keyguard: %s:%s: %w", commit, path, ErrNotFound)
+		}
+		return nil, fmt.Errorf("keyguard: git cat-file %s:%s: %w", commit, path, err)
+	}
+	return data, nil
+}
+
+// Mode runs one git process.  An unknown commit or path fails with
+// ErrNotFound.
+func (r *ExecRepo) Mode(commit, path string) (string, error) {
+	out, err := gitOutput(r.Root, "ls-tree", "--full-tree", commit+"^{commit}", "--", path)
+	if err != nil {
+		if isExitError(err) {
+			return "", fmt.Errorf("keyguard: %s: %w", commit, ErrNotFound)
+		}
+		return "", fmt.Errorf("keyguard: git ls-tree %s %s: %w", commit, path, err)
+	}
+	// "<mode> <type> <object>\t<path>"
+	mode, _, _ := strings.Cut(out, " ")
+	if mode == "" {
+		return "", fmt.Errorf("keyguard: %s:%s: %w", commit, path, ErrNotFound)
+	}
+	return strings.TrimLeft(mode, "0"), nil
+}
+
+func (r *ExecRepo) ListFiles(commit string) ([]string, error) {
+	out, err := gitRaw(r.Root, "ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return nil, fmt.Errorf("keyguard: git ls-tree %s: %w", commit, err)
+	}
+	return nulFields(out), nil
+}
+
+func (r *ExecRepo) ChangedFiles(from, to string) ([]string, error) {
+	out, err := gitRaw(r.Root, "diff", "-z", "--name-only", "--diff-filter=ACMR", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("keyguard: git diff: %w", err)
+	}
+	return nulFields(out), nil
+}
+
+func (r *ExecRepo) AddedHunks(from, to string) (map[string][]Hunk, error) {
+	// Explicit prefixes, so diff.noprefix or diff.mnemonicPrefix in the
+	// user's config cannot change the "+++ b/<path>" lines parsed.
+	out, err := gitOutput(r.Root, "diff", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/",
+		"-U0", "--diff-filter=ACMR", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("keyguard: git diff -U0: %w", err)
+	}
+	return parseAddedHunks(out)
+}
+
+func (r *ExecRepo) Log(from, to string) ([]Commit, error) {
+	// With -z each commit is "\x00<sha>\n<message>\x00", followed by its
+	// files, the first after a newline, each ending in NUL.  File names are
+	// never empty, so an empty field starts the next commit.
+	out, err := gitRaw(r.Root, "log", "-z", "--no-color", "--no-merges",
+		"--format=%x00%H%n%B", "--name-only", "--diff-filter=ACMR", from+".."+to)
+	if err != nil {
+		return nil, fmt.Errorf("keyguard: git log %s..%s: %w", from, to, err)
+	}
+	var commits []Commit
+	fields := nulFields(out)
+	for i := 0; i < len(fields); i++ {
+		if fields[i] != "" || i+1 == len(fields) {
+			return nil, fmt.Errorf("keyguard: git log %s..%s: malformed output", from, to)
+		}
+		i++
+		sha, msg, _ := strings.Cut(fields[i], "\n")
+		c := Commit{SHA: sha, Message: strings.TrimSpace(msg)}
+		for i+1 < len(fields) && fields[i+1] != "" {
+			i++
+			name := fields[i]
+			if len(c.Files) == 0 {
+				name = strings.TrimPrefix(name, "\n")
+			}
+			c.Files = append(c.Files, name)
+		}
+		if len(c.Files) > 0 {
+			commits = append(commits, c)
+		}
+	}
+	return commits, nil
+}
+
+// Fetch updates origin/<ref> from origin.
+func (r *ExecRepo) Fetch(ref string) error {
+	cmd := exec.Command("git", "fetch", "--no-tags", "origin", ref)
+	cmd.Dir = r.Root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("keyguard: git fetch origin %s: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// isExitError reports whether err is git exiting unsuccessfully (as
+// opposed to git failing to start).
+func isExitError(err error) bool {
+	var ee *exec.ExitError
+	return errors.As(err, &ee)
+}
@@ -0,0 +1,132 @@
+package keyguard
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FakeRepo is an in-memory GitRepo for unit tests.  It stores real git
+// objects, so SHAs, trees and diffs behave exactly as in NativeRepo:
+//
+//	r := keyguard.NewFakeRepo()
+//	base := r.Commit("init", map[string]string{"key.agents_.md": "Key: AIKEY-…\n"})
+//	r.SetRef("refs/remotes/origin/main", base)
+//	r.Commit("add main.go", map[string]string{"main.go": "package main\n"}, base)
+type FakeRepo struct {
+	objectRepo
+	mem *memStore
+}
+
+// NewFakeRepo returns an empty repository.  HEAD is unborn until the first
+// Commit.
+func NewFakeRepo() *FakeRepo {
+	s := &memStore{objects: map[string]cachedObject{}, refs: map[string]string{}}
+	return &FakeRepo{objectRepo: objectRepo{store: s}, mem: s}
+}
+
+// Commit records a commit whose tree holds exactly files (path → content)
+// with the given parents, moves HEAD to it and returns its SHA.  With no
+// parents the current HEAD, if any, is used.  Committer times increase by
+// one second per commit so walks are ordered deterministically.
+func (r *FakeRepo) Commit(message string, files map[string]string, parents ...string) string {
+	if parents == nil {
+		if head, err := r.mem.ref("HEAD"); err == nil {
+			parents = []string{head}
+		}
+	}
+	tree := r.writeTree(files, "")
+	r.mem.clock++
+	var b strings.Builder
+	fmt.Fprintf(&b, "tree %s\n", tree)
+	for _, p := range parents {
+		fmt.Fprintf(&b, "parent %s\n", p)
+	}
+	fmt.Fprintf(&b, "author Fake <fake@example.com> %d +0000\n", 1700000000+r.mem.clock)
+	fmt.Fprintf(&b, "committer Fake <fake@example.com> %d +0000\n", 1700000000+r.mem.clock)
+	fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(message, "\n"))
+	sha := r.mem.put("commit", []byte(b.String()))
+	r.mem.refs["HEAD"] = sha
+	return sha
+}
+
+// SetRef points the fully qualified ref name (e.g. "refs/heads/main",
+// "refs/remotes/origin/main") or "HEAD" at sha.
+func (r *FakeRepo) SetRef(name, sha string) {
+	r.mem.refs[name] = sha
+}
+
+// writeTree stores the tree for the files under dir and returns its SHA.
+func (r *FakeRepo) writeTree(files map[string]string, dir string) string {
+	type entry struct {
+		name, mode, sha string
+	}
+	var entries []entry
+	subdirs := map[string]bool{}
+	for p, content := range files {
+		rest, ok := strings.CutPrefix(p, dir)
+		if !ok {
+			continue
+		}
+		if name, _, nested := strings.Cut(rest, "/"); nested {
+			if !subdirs[name] {
+				subdirs[name] = true
+				entries = append(entries, entry{name, "40000", r.writeTree(files, dir+name+"/")})
+			}
+			continue
+		}
+		entries = append(entries, entry{rest, "100644", r.mem.put("blob", []byte(content))})
+	}
+	// git orders tree entries by name, with directories compared as if
+	// they ended in "/".
+	key := func(e entry) string {
+		if e.mode == "40000" {
+			return e.name + "/"
+		}
+		return e.name
+	}
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i]) < key(entries[j]) })
+	var b []byte
+	for _, e := range entries {
+		raw, _ := hex.DecodeString(e.sha)
+		b = append(b, e.mode+" "+e.name+"\x00"...)
+		b = append(b, raw...)
+	}
+	return r.mem.put("tree", b)
+}
+
+// memStore is the objectStore behind FakeRepo.
+type memStore struct {
+	objects map[string]cachedObject
+	refs    map[string]string
+	clock   int64
+}
+
+func (s *memStore) put(kind string, data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", kind, len(data))
+	h.Write(data)
+	sha := fmt.Sprintf("%x", h.Sum(nil))
+	s.objects[sha] = cachedObject{kind, data}
+	return sha
+}
+
+func (s *memStore) object(sha string) (string, []byte, error) {
+	o, ok := s.objects[sha]
+	if !ok {
+		return "", nil, fmt.Errorf("keyguard: object %s: %w", sha, ErrNotFound)
+	}
+	return o.kind, o.data, nil
+}
+
+func (s *memStore) ref(name string) (string, error) {
+	sha, ok := s.refs[name]
+	if !ok {
+		return "", fmt.Errorf("keyguard: ref %s: %w", name, ErrNotFound)
+	}
+	return sha, nil
+}
+
+func (s *memStore) shallow(string) bool { return false }
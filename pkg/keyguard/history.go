@@ -0,0 +1,171 @@
+package keyguard
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryFile is the key ledger inject_key keeps beside key.agents_.md.
+// Each line records one key generation, oldest first:
+//
+//	<generation> <since, RFC 3339 or "-"> <key>
+//
+// "since" is when the key became current; "-" marks a key that predates the
+// ledger.  Lines starting with "#" are comments.
+const HistoryFile = "key.history"
+
+// MaxHistory bounds the number of generations inject_key keeps.
+const MaxHistory = 50
+
+// KeyGeneration is one entry of the key history.
+type KeyGeneration struct {
+	Generation int
+	Key        string
+	Since      time.Time // zero when unknown
+}
+
+// KeyHistory is the key ledger, oldest generation first.
+type KeyHistory []KeyGeneration
+
+// ParseKeyHistory parses the content of HistoryFile.
+func ParseKeyHistory(data []byte) (KeyHistory, error) {
+	var h KeyHistory
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 3 || !strings.HasPrefix(f[2], "AIKEY-") {
+			return nil, fmt.Errorf("keyguard: %s:%d: want \"<generation> <since> <key>\"", HistoryFile, i+1)
+		}
+		gen, err := strconv.Atoi(f[0])
+		if err != nil || gen < 1 {
+			return nil, fmt.Errorf("keyguard: %s:%d: bad generation %q", HistoryFile, i+1, f[0])
+		}
+		g := KeyGeneration{Generation: gen, Key: f[2]}
+		if f[1] != "-" {
+			if g.Since, err = time.Parse(time.RFC3339, f[1]); err != nil {
+				return nil, fmt.Errorf("keyguard: %s:%d: bad timestamp %q", HistoryFile, i+1, f[1])
+			}
+		}
+		h = append(h, g)
+	}
+	return h, nil
+}
+
+// Format renders h in the HistoryFile format.
+func (h KeyHistory) Format() string {
+	var b strings.Builder
+	b.WriteString("# AI submission key history, managed by inject_key.  Oldest first.\n")
+	b.WriteString("# <generation> <since> <key>\n")
+	for _, g := range h {
+		since := "-"
+		if !g.Since.IsZero() {
+			since = g.Since.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "%d %s %s\n", g.Generation, since, g.Key)
+	}
+	return b.String()
+}
+
+// Rotate returns h with key appended as the next generation, current from
+// now, keeping at most MaxHistory entries.  If previous is set but h does
+// not record it (the ledger is new, edited by hand or written by an older
+// tool), previous is recorded first with an unknown start, so it is
+// superseded now and gets its grace window.
+func (h KeyHistory) Rotate(previous, key string, now time.Time) KeyHistory {
+	if _, ok := h.Lookup(previous); previous != "" && !ok {
+		h = append(h, KeyGeneration{Generation: h.next(), Key: previous})
+	}
+	h = append(h, KeyGeneration{Generation: h.next(), Key: key, Since: now.UTC().Truncate(time.Second)})
+	if len(h) > MaxHistory {
+		h = h[len(h)-MaxHistory:]
+	}
+	return h
+}
+
+// next is the generation number after the latest in h.
+func (h KeyHistory) next() int {
+	if len(h) == 0 {
+		return 1
+	}
+	return h[len(h)-1].Generation + 1
+}
+
+// Lookup returns the generation that key was, if h records it.
+func (h KeyHistory) Lookup(key string) (KeyGeneration, bool) {
+	for _, g := range h {
+		if g.Key == key {
+			return g, true
+		}
+	}
+	return KeyGeneration{}, false
+}
+
+// Superseded returns when the generation after key's became current: the
+// moment key stopped being valid.  It reports false when key is the latest
+// generation, is not recorded, or its successor's start is unknown.
+func (h KeyHistory) Superseded(key string) (time.Time, bool) {
+	for i, g := range h {
+		if g.Key == key && i+1 < len(h) && !h[i+1].Since.IsZero() {
+			return h[i+1].Since, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// AcceptedKeys returns the keys a submission may carry: current first, then
+// every earlier generation superseded less than grace before now, newest
+// first.
+func (h KeyHistory) AcceptedKeys(current string, now time.Time, grace time.Duration) []string {
+	keys := []string{current}
+	if grace <= 0 {
+		return keys
+	}
+	for i := len(h) - 1; i >= 0; i-- {
+		key := h[i].Key
+		if key == current {
+			continue
+		}
+		if until, ok := h.Superseded(key); ok && now.Sub(until) <= grace {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ReadKeyHistoryAtCommit reads HistoryFile from commitSHA's tree.  Returns
+// (nil, nil) when the commit has no ledger.
+func ReadKeyHistoryAtCommit(repo GitRepo, commitSHA string) (KeyHistory, error) {
+	data, err := ReadFileAtCommit(repo, commitSHA, HistoryFile)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return ParseKeyHistory(data)
+}
+
+// GraceFromEnv reads AI_KEY_GRACE: how long a rotated-out key is still
+// accepted, as a Go duration ("36h") or a number of days ("7d").  Unset
+// means no grace: only the current key is accepted.
+func GraceFromEnv() (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv("AI_KEY_GRACE"))
+	if v == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("keyguard: invalid AI_KEY_GRACE %q", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("keyguard: invalid AI_KEY_GRACE %q", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,226 @@
+package keyguard
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testdata/key.history is also parsed and formatted by the Rust
+// inject-key (history_matches_go_fixture): both must read the same ledger
+// and write it back byte for byte.
+func TestKeyHistoryFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "key.history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := ParseKeyHistory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := KeyHistory{
+		{Generation: 1, Key: "AIKEY-firstkey234567"},
+		{Generation: 2, Key: "AIKEY-secondkey23456", Since: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{Generation: 3, Key: "AIKEY-thirdkey234567", Since: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("ParseKeyHistory = %+v\nwant %+v", h, want)
+	}
+	if got := h.Format(); got != string(data) {
+		t.Errorf("Format = %q\nwant %q", got, data)
+	}
+}
+
+func TestParseKeyHistory(t *testing.T) {
+	tests := []struct {
+		name, data string
+		want       KeyHistory
+		err        string
+	}{
+		{name: "empty", data: ""},
+		{
+			name: "comments, blank lines and an unknown since",
+			data: "# c\n\n  1 - AIKEY-a  \r\n2 2026-01-01T00:00:00+02:00 AIKEY-b\n",
+			want: KeyHistory{
+				{Generation: 1, Key: "AIKEY-a"},
+				{Generation: 2, Key: "AIKEY-b", Since: time.Date(2025, 12, 31, 22, 0, 0, 0, time.UTC)},
+			},
+		},
+		{name: "missing field", data: "1 AIKEY-a\n", err: ":1: want"},
+		{name: "not a key", data: "# c\n1 - notakey\n", err: ":2: want"},
+		{name: "generation 0", data: "0 - AIKEY-a\n", err: "bad generation"},
+		{name: "bad timestamp", data: "1 2026-01-01 AIKEY-a\n", err: "bad timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyHistory([]byte(tt.data))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseKeyHistory = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Generation != tt.want[i].Generation || got[i].Key != tt.want[i].Key || !got[i].Since.Equal(tt.want[i].Since) {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// An unknown since ("-") survives Format and ParseKeyHistory.
+func TestKeyHistoryRoundTrip(t *testing.T) {
+	h := KeyHistory{
+		{Generation: 1, Key: "AIKEY-a"},
+		{Generation: 2, Key: "AIKEY-b", Since: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)},
+	}
+	if !strings.Contains(h.Format(), "\n1 - AIKEY-a\n") {
+		t.Errorf("Format does not write an unknown since as \"-\":\n%s", h.Format())
+	}
+	got, err := ParseKeyHistory([]byte(h.Format()))
+	if err != nil || !reflect.DeepEqual(got, h) {
+		t.Errorf("round trip = %+v, %v; want %+v", got, err, h)
+	}
+}
+
+func TestKeyHistoryRotate(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 500, time.FixedZone("x", 3600))
+	at := now.UTC().Truncate(time.Second)
+	tests := []struct {
+		name          string
+		h             KeyHistory
+		previous, key string
+		want          KeyHistory
+	}{
+		{
+			name: "new ledger",
+			key:  "AIKEY-b",
+			want: KeyHistory{{Generation: 1, Key: "AIKEY-b", Since: at}},
+		},
+		{
+			name:     "new ledger with the previous key",
+			previous: "AIKEY-a", key: "AIKEY-b",
+			want: KeyHistory{{Generation: 1, Key: "AIKEY-a"}, {Generation: 2, Key: "AIKEY-b", Since: at}},
+		},
+		{
+			name:     "previous key recorded",
+			h:        KeyHistory{{Generation: 4, Key: "AIKEY-a"}},
+			previous: "AIKEY-a", key: "AIKEY-b",
+			want: KeyHistory{{Generation: 4, Key: "AIKEY-a"}, {Generation: 5, Key: "AIKEY-b", Since: at}},
+		},
+		{
+			// A hand-edited ledger lacking the outgoing key.
+			name:     "previous key missing",
+			h:        KeyHistory{{Generation: 1, Key: "AIKEY-x"}},
+			previous: "AIKEY-a", key: "AIKEY-b",
+			want: KeyHistory{{Generation: 1, Key: "AIKEY-x"}, {Generation: 2, Key: "AIKEY-a"}, {Generation: 3, Key: "AIKEY-b", Since: at}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.h.Rotate(tt.previous, tt.key, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rotate = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The outgoing key of a hand-edited ledger keeps its grace window.
+	h := KeyHistory{{Generation: 1, Key: "AIKEY-x"}}.Rotate("AIKEY-a", "AIKEY-b", now)
+	if got := h.AcceptedKeys("AIKEY-b", now.Add(time.Hour), 2*time.Hour); !reflect.DeepEqual(got, []string{"AIKEY-b", "AIKEY-a"}) {
+		t.Errorf("AcceptedKeys after Rotate = %q", got)
+	}
+}
+
+func TestKeyHistoryRotateTrims(t *testing.T) {
+	var h KeyHistory
+	for i := 0; i < MaxHistory+3; i++ {
+		h = h.Rotate("", "AIKEY-"+strings.Repeat("a", i+1), time.Unix(int64(i), 0))
+	}
+	if len(h) != MaxHistory || h[0].Generation != 4 || h[len(h)-1].Generation != MaxHistory+3 {
+		t.Errorf("Rotate kept %d entries, generations %d..%d; want %d, 4..%d",
+			len(h), h[0].Generation, h[len(h)-1].Generation, MaxHistory, MaxHistory+3)
+	}
+}
+
+func TestKeyHistorySupersededAndAccepted(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := KeyHistory{
+		{Generation: 1, Key: "AIKEY-one"},
+		{Generation: 2, Key: "AIKEY-two"}, // unknown since: one's end is unknown
+		{Generation: 3, Key: "AIKEY-three", Since: t0},
+		{Generation: 4, Key: "AIKEY-four", Since: t0.Add(24 * time.Hour)},
+	}
+	for _, tt := range []struct {
+		key  string
+		want time.Time
+		ok   bool
+	}{
+		{"AIKEY-one", time.Time{}, false},
+		{"AIKEY-two", t0, true},
+		{"AIKEY-three", t0.Add(24 * time.Hour), true},
+		{"AIKEY-four", time.Time{}, false}, // current
+		{"AIKEY-unknown", time.Time{}, false},
+	} {
+		if got, ok := h.Superseded(tt.key); !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("Superseded(%s) = %v, %v; want %v, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	now := t0.Add(48 * time.Hour) // two superseded 48h ago, three 24h ago
+	for _, tt := range []struct {
+		grace time.Duration
+		want  []string
+	}{
+		{0, []string{"AIKEY-four"}},
+		{24*time.Hour - time.Second, []string{"AIKEY-four"}},
+		{24 * time.Hour, []string{"AIKEY-four", "AIKEY-three"}}, // the boundary is inclusive
+		{48 * time.Hour, []string{"AIKEY-four", "AIKEY-three", "AIKEY-two"}},
+		{1000 * time.Hour, []string{"AIKEY-four", "AIKEY-three", "AIKEY-two"}},
+	} {
+		if got := h.AcceptedKeys("AIKEY-four", now, tt.grace); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AcceptedKeys(grace %s) = %q, want %q", tt.grace, got, tt.want)
+		}
+	}
+	// A current key the ledger does not know is still accepted, first; the
+	// ledger's last key has no recorded successor, so it has no grace.
+	if got := h.AcceptedKeys("AIKEY-new", now, 24*time.Hour); !reflect.DeepEqual(got, []string{"AIKEY-new", "AIKEY-three"}) {
+		t.Errorf("AcceptedKeys with an unrecorded current key = %q", got)
+	}
+}
+
+func TestGraceFromEnv(t *testing.T) {
+	tests := []struct {
+		env  string
+		want time.Duration
+		err  bool
+	}{
+		{"", 0, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{" 0d ", 0, false},
+		{"36h", 36 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"1.5d", 0, true}, // days are whole
+		{"-1d", 0, true},
+		{"-1h", 0, true},
+		{"7days", 0, true},
+		{"7", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("AI_KEY_GRACE", tt.env)
+		got, err := GraceFromEnv()
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("GraceFromEnv(%q) = %s, %v; want %s, error %v", tt.env, got, err, tt.want, tt.err)
+		}
+	}
+}
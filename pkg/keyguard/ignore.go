@@ -0,0 +1,118 @@
+package keyguard
+
+import (
+	"path"
+	"strings"
+
+	"github.com/portal-co/scripts/internal/glob"
+)
+
+// IgnoreFile lists, in gitignore syntax, paths exempt from key enforcement
+// (vendored code, generated bindings, lockfiles).  check_ai_key reads it at
+// the anchor commit, so a submission cannot exempt itself.
+const IgnoreFile = ".aikeyignore"
+
+// IgnoreRule is one pattern line of an IgnoreFile.
+type IgnoreRule struct {
+	Pattern string // the line as written
+	Line    int
+
+	negate   bool     // "!pattern" re-includes
+	dirOnly  bool     // "pattern/" matches directories only
+	anchored bool     // contains a "/": matched against the whole path
+	segments []string // split on "/"; "**" matches any number of segments
+}
+
+// Ignore is a parsed IgnoreFile.  As with gitignore, the last matching
+// rule wins, and a file inside an ignored directory cannot be re-included.
+type Ignore []IgnoreRule
+
+// ParseIgnore parses gitignore syntax: "#" comments, "!" negation, a
+// leading "/" or inner "/" to anchor at the repository root, a trailing "/"
+// for directories, "*", "?" and "[...]" within a path segment and "**"
+// across segments.  Backslash escapes a leading "#" or "!".
+func ParseIgnore(data []byte) Ignore {
+	var ig Ignore
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := IgnoreRule{Pattern: line, Line: i + 1}
+		p := line
+		if strings.HasPrefix(p, "!") {
+			r.negate, p = true, p[1:]
+		} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			r.dirOnly, p = true, strings.TrimRight(p, "/")
+		}
+		if strings.Contains(p, "/") {
+			r.anchored, p = true, strings.TrimPrefix(p, "/")
+		}
+		if p == "" {
+			continue
+		}
+		r.segments = strings.Split(p, "/")
+		ig = append(ig, r)
+	}
+	return ig
+}
+
+// Match reports whether the file at rel (slash-separated, relative to the
+// repository root) is ignored, and by which rule.
+func (ig Ignore) Match(rel string) (bool, IgnoreRule) {
+	parts := strings.Split(rel, "/")
+	for n := 1; n <= len(parts); n++ {
+		isDir := n < len(parts)
+		ignored, rule := false, IgnoreRule{}
+		for _, r := range ig {
+			if r.matches(parts[:n], isDir) {
+				ignored, rule = !r.negate, r
+			}
+		}
+		if ignored {
+			return true, rule
+		}
+	}
+	return false, IgnoreRule{}
+}
+
+// Filter splits files into those kept and those ignored, the latter mapped
+// to the rule that ignores them.
+func (ig Ignore) Filter(files []string) (kept []string, ignored map[string]IgnoreRule) {
+	ignored = map[string]IgnoreRule{}
+	for _, f := range files {
+		if ok, r := ig.Match(f); ok {
+			ignored[f] = r
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, ignored
+}
+
+func (r IgnoreRule) matches(parts []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return glob.MatchSegments(r.segments, parts)
+}
+
+// ReadIgnoreAtCommit reads IgnoreFile from commitSHA's tree.  A commit
+// without one ignores nothing.
+func ReadIgnoreAtCommit(repo GitRepo, commitSHA string) (Ignore, error) {
+	data, err := ReadFileAtCommit(repo, commitSHA, IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIgnore(data), nil
+}
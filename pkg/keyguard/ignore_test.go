@@ -0,0 +1,101 @@
+package keyguard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		ignore string
+		want   map[string]bool // path -> ignored
+	}{
+		{
+			name:   "unanchored matches at any depth",
+			ignore: "*.lock\ngen\n",
+			want:   map[string]bool{"Cargo.lock": true, "a/b/yarn.lock": true, "gen": true, "a/gen/x.go": true, "a/generated.go": false},
+		},
+		{
+			name:   "anchored",
+			ignore: "/gen\ndocs/*.md\n",
+			want:   map[string]bool{"gen": true, "gen/x.go": true, "a/gen/x.go": false, "docs/a.md": true, "docs/x/a.md": false, "x/docs/a.md": false},
+		},
+		{
+			name:   "directories only",
+			ignore: "build/\n",
+			want:   map[string]bool{"build/out.bin": true, "a/build/out.bin": true, "build": false, "a/build": false},
+		},
+		{
+			name:   "double star",
+			ignore: "**/fixtures\na/**/c.go\nvendor/**\n",
+			want: map[string]bool{
+				"fixtures/x": true, "a/b/fixtures/x": true, "a/c.go": true, "a/x/y/c.go": true, "b/c.go": false,
+				"vendor/x/y.go": true, "vendored/y.go": false,
+			},
+		},
+		{
+			name:   "last match wins",
+			ignore: "*.go\n!keep.go\n",
+			want:   map[string]bool{"a.go": true, "keep.go": false, "a/keep.go": false},
+		},
+		{
+			name:   "a negation before the pattern it undoes has no effect",
+			ignore: "!keep.go\n*.go\n",
+			want:   map[string]bool{"a.go": true, "keep.go": true},
+		},
+		{
+			name:   "no re-including a file in an ignored directory",
+			ignore: "vendor/\n!vendor/keep.go\n",
+			want:   map[string]bool{"vendor/a.go": true, "vendor/keep.go": true},
+		},
+		{
+			name:   "re-including a directory",
+			ignore: "vendor/*\n!vendor/keep/\n",
+			want:   map[string]bool{"vendor/a.go": true, "vendor/keep/a.go": false},
+		},
+		{
+			name:   "comments and blank lines",
+			ignore: "# notes\n\n  \n#*.go\n",
+			want:   map[string]bool{"# notes": false, "#*.go": false, "a.go": false},
+		},
+		{
+			name:   "escaped hash and bang",
+			ignore: "\\#notes\n\\!important\n",
+			want:   map[string]bool{"#notes": true, "a/#notes": true, "!important": true, "notes": false, "important": false},
+		},
+		{
+			name:   "trailing whitespace is trimmed unless escaped",
+			ignore: "a.txt  \r\nb\\ \n",
+			want:   map[string]bool{"a.txt": true, "b ": true, "b": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ig := ParseIgnore([]byte(tt.ignore))
+			for path, want := range tt.want {
+				if got, _ := ig.Match(path); got != want {
+					t.Errorf("Match(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+// Match and Filter report the rule that decided, by its line in the file.
+func TestIgnoreFilter(t *testing.T) {
+	ig := ParseIgnore([]byte("# generated\n*.pb.go\n\nvendor/\n!keep.pb.go\n"))
+	kept, ignored := ig.Filter([]string{"a.go", "a.pb.go", "keep.pb.go", "vendor/x/keep.pb.go"})
+	if want := []string{"a.go", "keep.pb.go"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept = %q, want %q", kept, want)
+	}
+	want := map[string]IgnoreRule{"a.pb.go": {Pattern: "*.pb.go", Line: 2}, "vendor/x/keep.pb.go": {Pattern: "vendor/", Line: 4}}
+	if len(ignored) != len(want) {
+		t.Fatalf("ignored = %+v", ignored)
+	}
+	for path, r := range want {
+		if got := ignored[path]; got.Pattern != r.Pattern || got.Line != r.Line {
+			t.Errorf("%s ignored by %q (line %d), want %q (line %d)", path, got.Pattern, got.Line, r.Pattern, r.Line)
+		}
+	}
+}
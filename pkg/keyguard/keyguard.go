@@ -1,16 +1,18 @@
 // Package keyguard provides helpers for reading the AI submission key from
 // a repository's key.agents_.md (or AGENTS.md fallback), resolving the
-// correct anchor commit for a CI context, and scanning submission files for
-// the key.
+// correct anchor commit for a CI context, and scanning submission files and
+// commit messages for the key.
 package keyguard
 
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -43,14 +45,17 @@ func ReadKey(repoRoot string) (string, error) {
 
 // ReadKeyAtCommit reads the AI submission key from a specific git commit
 // object.  It tries key.agents_.md then AGENTS.md inside the commit tree.
-// Returns ("", nil) when the commit contains neither file or neither has a key.
-func ReadKeyAtCommit(repoRoot, commitSHA string) (string, error) {
+// Returns ("", nil) when the commit contains neither file or neither has a
+// key; any other failure to read the commit is returned as an error.
+func ReadKeyAtCommit(repo GitRepo, commitSHA string) (string, error) {
 	for _, name := range candidateFiles {
-		data, err := gitShow(repoRoot, commitSHA, name)
-		if err != nil {
-			// git show exits non-zero when the path doesn't exist in the tree.
+		data, err := repo.ReadFile(commitSHA, name)
+		if errors.Is(err, ErrNotFound) {
 			continue
 		}
+		if err != nil {
+			return "", err
+		}
 		if k := extractKey(data); k != "" {
 			return k, nil
 		}
@@ -58,36 +63,54 @@ func ReadKeyAtCommit(repoRoot, commitSHA string) (string, error) {
 	return "", nil
 }
 
+// ReadFileAtCommit returns the content of path (relative to the repo root)
+// in commitSHA's tree.  Returns (nil, nil) when the commit has no such file.
+func ReadFileAtCommit(repo GitRepo, commitSHA, path string) ([]byte, error) {
+	data, err := repo.ReadFile(commitSHA, path)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// ListFilesAtCommit returns the paths (relative to the repo root) of every
+// file in commitSHA's tree.
+func ListFilesAtCommit(repo GitRepo, commitSHA string) ([]string, error) {
+	return repo.ListFiles(commitSHA)
+}
+
 // BaseCommit resolves the anchor commit that the CI check should use as the
 // baseline for both reading the expected key and determining which files were
 // changed in this submission.
 //
 // Resolution rules:
 //
-//   - pull_request event: git merge-base HEAD origin/$GITHUB_BASE_REF
+//   - pull/merge request with a provider-computed diff base (GitLab's
+//     CI_MERGE_REQUEST_DIFF_BASE_SHA): that commit
+//   - other pull/merge requests: git merge-base HEAD origin/<base ref>
 //   - push event with a parent commit: HEAD^ (the immediate parent)
 //   - push of an orphan / initial commit: returns ("", nil) — caller should skip
-func BaseCommit(repoRoot string) (string, error) {
-	event := strings.TrimSpace(os.Getenv("GITHUB_EVENT_NAME"))
-	baseRef := strings.TrimSpace(os.Getenv("GITHUB_BASE_REF"))
-
-	if event == "pull_request" && baseRef != "" {
+func BaseCommit(repo GitRepo, ci CIContext) (string, error) {
+	if baseRef := ci.BaseRef(); ci.Event() == EventPullRequest && baseRef != "" {
 		// Fetch the base ref so merge-base works even with a shallow clone.
-		_ = runGit(repoRoot, "fetch", "--no-tags", "origin", baseRef)
-		sha, err := gitOutput(repoRoot, "merge-base", "HEAD", "origin/"+baseRef)
-		if err != nil {
-			return "", fmt.Errorf("keyguard: git merge-base HEAD origin/%s: %w", baseRef, err)
+		if f, ok := repo.(Fetcher); ok {
+			_ = f.Fetch(baseRef)
 		}
-		return sha, nil
+		if sha := ci.BaseSHA(); sha != "" {
+			if resolved, err := repo.Resolve(sha); err == nil {
+				return resolved, nil
+			}
+		}
+		return repo.MergeBase("HEAD", "origin/"+baseRef)
 	}
 
 	// push (or local): use the immediate parent commit.
-	sha, err := gitOutput(repoRoot, "rev-parse", "HEAD^")
-	if err != nil {
-		// HEAD^ fails on an orphan / initial commit — no anchor, skip.
+	sha, err := repo.Resolve("HEAD^")
+	if errors.Is(err, ErrNotFound) {
+		// HEAD^ does not exist on an orphan / initial commit — no anchor, skip.
 		return "", nil
 	}
-	return sha, nil
+	return sha, err
 }
 
 // ChangedFiles returns the list of files changed between anchorSHA and HEAD,
@@ -96,31 +119,213 @@ func BaseCommit(repoRoot string) (string, error) {
 //
 // For a pull_request anchor this gives the full PR diff; for a push anchor
 // (HEAD^) it gives exactly the files changed in that push.
-func ChangedFiles(repoRoot, anchorSHA string) ([]string, error) {
-	out, err := gitOutput(repoRoot, "diff", "--name-only", "--diff-filter=ACMR", anchorSHA, "HEAD")
-	if err != nil {
-		return nil, fmt.Errorf("keyguard: git diff: %w", err)
+func ChangedFiles(repo GitRepo, anchorSHA string) ([]string, error) {
+	return repo.ChangedFiles(anchorSHA, "HEAD")
+}
+
+// Hunk is a run of lines added on the HEAD side of a diff: lines Start
+// through Start+Count-1 (1-based) of the file at HEAD.
+type Hunk struct {
+	Start, Count int
+}
+
+// AddedHunks returns, for every file changed between anchorSHA and HEAD,
+// the hunks of lines that HEAD adds or rewrites.  Files with only deletions
+// map to an empty slice.  Paths are relative to the repo root, as in
+// ChangedFiles.
+func AddedHunks(repo GitRepo, anchorSHA string) (map[string][]Hunk, error) {
+	return repo.AddedHunks(anchorSHA, "HEAD")
+}
+
+// hunkHeader matches a unified diff hunk header and captures the old-side
+// count and the new-side start and count (counts are optional).
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+func parseAddedHunks(diff string) (map[string][]Hunk, error) {
+	hunks := map[string][]Hunk{}
+	var current string
+	// Lines of the current hunk's body still to come on each side: inside
+	// a body, "+++ x" is an added line "++ x", not a file header.
+	oldLeft, newLeft := 0, 0
+	for _, line := range strings.Split(diff, "\n") {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "\\"):
+				// "\ No newline at end of file"
+			default:
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			// git ends the name with a tab when it contains a space.
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t")
+			if strings.HasPrefix(name, `"`) {
+				unq, err := strconv.Unquote(name)
+				if err != nil {
+					return nil, fmt.Errorf("keyguard: diff: bad path %s", name)
+				}
+				name = unq
+			}
+			if name == "/dev/null" {
+				current = ""
+				continue
+			}
+			current = strings.TrimPrefix(name, "b/")
+			hunks[current] = hunks[current][:0:0]
+		case strings.HasPrefix(line, "@@ "):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("keyguard: diff: bad hunk header %q", line)
+			}
+			oldLeft, newLeft = 1, 1
+			if m[1] != "" {
+				oldLeft, _ = strconv.Atoi(m[1])
+			}
+			start, _ := strconv.Atoi(m[2])
+			if m[3] != "" {
+				newLeft, _ = strconv.Atoi(m[3])
+			}
+			if newLeft > 0 && current != "" {
+				hunks[current] = append(hunks[current], Hunk{Start: start, Count: newLeft})
+			}
+		}
 	}
-	return nonEmptyLines(out), nil
+	return hunks, nil
 }
 
 // ScanForKey checks each file path (relative to repoRoot) for the presence of
 // key as a literal string.  It returns the subset of paths that do NOT contain
 // the key.
 func ScanForKey(repoRoot string, paths []string, key string) (missing []string, err error) {
+	_, missing, err = ScanForKeys(WorkTreeContent{Root: repoRoot}, paths, []string{key})
+	return missing, err
+}
+
+// ScanForKeys is ScanForKey for several accepted keys, reading the files
+// from src.  found maps each path containing one of keys to the first of
+// them it contains; missing lists the paths containing none.
+func ScanForKeys(src Content, paths []string, keys []string) (found map[string]string, missing []string, err error) {
+	found = map[string]string{}
 	for _, rel := range paths {
-		data, readErr := os.ReadFile(fmt.Sprintf("%s/%s", repoRoot, rel))
+		data, _, readErr := src.ReadFile(rel)
 		if readErr != nil {
-			if os.IsNotExist(readErr) {
+			if errors.Is(readErr, ErrNotFound) {
 				continue // deleted files won't be in the tree
 			}
-			return nil, fmt.Errorf("keyguard: read %s: %w", rel, readErr)
+			return nil, nil, readErr
+		}
+		key := ""
+		for _, k := range keys {
+			if bytes.Contains(data, []byte(k)) {
+				key = k
+				break
+			}
 		}
-		if !bytes.Contains(data, []byte(key)) {
+		if key == "" {
 			missing = append(missing, rel)
+		} else {
+			found[rel] = key
 		}
 	}
-	return missing, nil
+	return found, missing, nil
+}
+
+// Commit is one commit of a submission.
+type Commit struct {
+	SHA     string
+	Message string
+	// Files lists the paths the commit adds or modifies (relative to the
+	// repo root).
+	Files []string
+}
+
+// SubmissionCommits returns the commits in anchorSHA..HEAD, newest first,
+// with their messages and the files each one touches.  Merge commits and
+// commits that only delete files are left out.
+func SubmissionCommits(repo GitRepo, anchorSHA string) ([]Commit, error) {
+	return repo.Log(anchorSHA, "HEAD")
+}
+
+// Trailer is a "Token: value" line from the trailer block (the last
+// paragraph) of a commit message.
+type Trailer struct {
+	Token, Value string
+}
+
+// trailerLine matches a git trailer: a token of letters, digits and
+// hyphens, a colon, and a value.
+var trailerLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s*(.*)$`)
+
+// ParseTrailers returns the trailers of message.  As with git, the last
+// paragraph is a trailer block only if every non-continuation line in it is
+// a trailer, and a message consisting of a single paragraph has none.
+func ParseTrailers(message string) []Trailer {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(message), "\r\n", "\n"), "\n")
+	// Paragraphs are separated by blank or whitespace-only lines.
+	start := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	var trailers []Trailer
+	for _, line := range lines[start:] {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(trailers) > 0 {
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		m := trailerLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			return nil
+		}
+		trailers = append(trailers, Trailer{Token: m[1], Value: strings.TrimSpace(m[2])})
+	}
+	return trailers
+}
+
+// KeyTrailer is the commit trailer that carries the submission key.
+const KeyTrailer = "AI-Key"
+
+// CommitKey reports how c carries key: "trailer" for an AI-Key trailer,
+// "message" for the key anywhere else in the message, "" when it does not.
+func CommitKey(c Commit, key string) string {
+	for _, t := range ParseTrailers(c.Message) {
+		if strings.EqualFold(t.Token, KeyTrailer) && t.Value == key {
+			return "trailer"
+		}
+	}
+	if strings.Contains(c.Message, key) {
+		return "message"
+	}
+	return ""
+}
+
+// KeyedByCommits maps every file touched by a commit that carries key to
+// the first such commit (in the order of commits).
+func KeyedByCommits(commits []Commit, key string) map[string]Commit {
+	keyed := map[string]Commit{}
+	for _, c := range commits {
+		if CommitKey(c, key) == "" {
+			continue
+		}
+		for _, f := range c.Files {
+			if _, seen := keyed[f]; !seen {
+				keyed[f] = c
+			}
+		}
+	}
+	return keyed
 }
 
 // ─── internal helpers ────────────────────────────────────────────────────────
@@ -133,13 +338,6 @@ func extractKey(data []byte) string {
 	return string(m[1])
 }
 
-func gitShow(repoRoot, commitSHA, path string) ([]byte, error) {
-	ref := commitSHA + ":" + path
-	cmd := exec.Command("git", "show", ref)
-	cmd.Dir = repoRoot
-	return cmd.Output()
-}
-
 func gitOutput(repoRoot string, args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = repoRoot
@@ -150,10 +348,20 @@ func gitOutput(repoRoot string, args ...string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
-func runGit(repoRoot string, args ...string) error {
+// gitRaw is gitOutput without trimming, for -z output.
+func gitRaw(repoRoot string, args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = repoRoot
-	return cmd.Run()
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// nulFields splits NUL-terminated -z output into its fields.
+func nulFields(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\x00"), "\x00")
 }
 
 func nonEmptyLines(s string) []string {
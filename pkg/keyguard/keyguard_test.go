@@ -0,0 +1,277 @@
+package keyguard
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// envMap is a getenv over a fixed environment.
+func envMap(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestBaseCommit(t *testing.T) {
+	r := NewFakeRepo()
+	root := r.Commit("init", map[string]string{"key.agents_.md": "Key: AIKEY-abcdefgh234567\n"})
+	base := r.Commit("base", map[string]string{"key.agents_.md": "Key: AIKEY-abcdefgh234567\n", "a.go": "package a\n"})
+	r.SetRef("refs/remotes/origin/main", r.Commit("main moves on", map[string]string{"key.agents_.md": "Key: AIKEY-abcdefgh234567\n", "b.go": "package b\n"}, base))
+	r.SetRef("HEAD", base)
+	first := r.Commit("pr 1", map[string]string{"key.agents_.md": "Key: AIKEY-abcdefgh234567\n", "a.go": "package a\n", "c.go": "package c\n"})
+	head := r.Commit("pr 2", map[string]string{"key.agents_.md": "Key: AIKEY-abcdefgh234567\n", "a.go": "package a\n", "c.go": "package c\n\nfunc C() {}\n"})
+
+	pr := map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_EVENT_NAME": "pull_request",
+		"GITHUB_BASE_REF":   "main",
+		"GITHUB_REF":        "refs/pull/3/merge",
+	}
+	gitlab := func(diffBase string) map[string]string {
+		return map[string]string{
+			"GITLAB_CI":                           "true",
+			"CI_MERGE_REQUEST_IID":                "3",
+			"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "main",
+			"CI_MERGE_REQUEST_DIFF_BASE_SHA":      diffBase,
+		}
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+		err  error
+	}{
+		{"local push", nil, first, nil},
+		{"github push", map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_EVENT_NAME": "push"}, first, nil},
+		{"pull request", pr, base, nil},
+		{"gitlab diff base", gitlab(root), root, nil},
+		{"gitlab unknown diff base", gitlab("5555555555555555555555555555555555555555"), base, nil},
+		{"unknown base branch", map[string]string{"GITLAB_CI": "true", "CI_MERGE_REQUEST_IID": "3", "CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "gone"}, "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.SetRef("HEAD", head)
+			got, err := BaseCommit(r, DetectCI(envMap(tt.env)))
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("BaseCommit = %q, %v; want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+
+	// An initial commit has no anchor: the check is skipped.
+	r.SetRef("HEAD", root)
+	if got, err := BaseCommit(r, DetectCI(envMap(nil))); got != "" || err != nil {
+		t.Errorf("BaseCommit of a root commit = %q, %v; want \"\", nil", got, err)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	r := NewFakeRepo()
+	anchor := r.Commit("init", map[string]string{
+		"key.agents_.md": "Key: AIKEY-abcdefgh234567\n",
+		"keep.go":        "package keep\n",
+		"edit.go":        "package edit\n",
+		"gone.go":        "package gone\n",
+		"dir/old.go":     "package dir\n",
+	})
+	r.Commit("change", map[string]string{
+		"key.agents_.md": "Key: AIKEY-abcdefgh234567\n",
+		"keep.go":        "package keep\n",
+		"edit.go":        "package edit\n\nfunc Edit() {}\n",
+		"dir/old.go":     "package dir\n",
+		"dir/sub/new.go": "package sub\n",
+		"added.md":       "# Added\n",
+	})
+	got, err := ChangedFiles(r, anchor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Deleted and unchanged files are left out; the rest is sorted.
+	if want := []string{"added.md", "dir/sub/new.go", "edit.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFiles = %q, want %q", got, want)
+	}
+	if got, err := ChangedFiles(r, "HEAD"); len(got) != 0 || err != nil {
+		t.Errorf("ChangedFiles(HEAD) = %q, %v; want none", got, err)
+	}
+	if _, err := ChangedFiles(r, "5555555555555555555555555555555555555555"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ChangedFiles of an unknown anchor: error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestParseAddedHunks(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want map[string][]Hunk
+	}{
+		{
+			name: "additions",
+			diff: "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,3 @@\n a\n+b\n c\n@@ -9 +10 @@\n-x\n+y\n",
+			want: map[string][]Hunk{"a.go": {{1, 3}, {10, 1}}},
+		},
+		{
+			name: "deletions only",
+			diff: "--- a/a.go\n+++ b/a.go\n@@ -3,2 +2,0 @@\n-x\n-y\n",
+			want: map[string][]Hunk{"a.go": nil},
+		},
+		{
+			name: "new file without a final newline",
+			diff: "--- /dev/null\n+++ b/new.md\n@@ -0,0 +1,2 @@\n+one\n+two\n\\ No newline at end of file\n",
+			want: map[string][]Hunk{"new.md": {{1, 2}}},
+		},
+		{
+			name: "no newline marker mid-hunk",
+			diff: "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1,2 @@\n-old\n\\ No newline at end of file\n+new\n+more\n",
+			want: map[string][]Hunk{"a.txt": {{1, 2}}},
+		},
+		{
+			name: "deleted file",
+			diff: "--- a/gone.go\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+			want: map[string][]Hunk{},
+		},
+		{
+			name: "rename with edits",
+			diff: "diff --git a/old.go b/dir/new.go\nsimilarity index 90%\nrename from old.go\nrename to dir/new.go\n" +
+				"--- a/old.go\n+++ b/dir/new.go\n@@ -4,2 +4,3 @@\n x\n+y\n z\n",
+			want: map[string][]Hunk{"dir/new.go": {{4, 3}}},
+		},
+		{
+			name: "exact rename",
+			diff: "diff --git a/old.go b/new.go\nsimilarity index 100%\nrename from old.go\nrename to new.go\n",
+			want: map[string][]Hunk{},
+		},
+		{
+			// "++ x" added and "-- x" removed look like file headers.
+			name: "header-like body lines",
+			diff: "--- a/a.md\n+++ b/a.md\n@@ -1,2 +1,2 @@\n--- x\n+++ x\n keep\n--- a/b.md\n+++ b/b.md\n@@ -0,0 +1 @@\n+b\n",
+			want: map[string][]Hunk{"a.md": {{1, 2}}, "b.md": {{1, 1}}},
+		},
+		{
+			name: "quoted path with a space",
+			diff: "--- \"a/sp ace\\tx.go\"\t\n+++ \"b/sp ace\\tx.go\"\t\n@@ -1 +1 @@\n-a\n+b\n",
+			want: map[string][]Hunk{"sp ace\tx.go": {{1, 1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAddedHunks(tt.diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAddedHunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := parseAddedHunks("+++ b/a.go\n@@ bad @@\n"); err == nil {
+		t.Errorf("bad hunk header: no error")
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		name, message string
+		want          []Trailer
+	}{
+		{"none", "Fix a bug\n\nLonger description.\n", nil},
+		{"subject only", "AI-Key: AIKEY-abcdefgh234567", nil},
+		{
+			"trailers",
+			"Fix a bug\n\nBody.\n\nAI-Key: AIKEY-abcdefgh234567\nSigned-off-by: A <a@example.com>\n",
+			[]Trailer{{"AI-Key", "AIKEY-abcdefgh234567"}, {"Signed-off-by", "A <a@example.com>"}},
+		},
+		{
+			"whitespace and CRLF",
+			"Fix\r\n \t\r\n  ai-key:   AIKEY-abcdefgh234567  \r\n",
+			[]Trailer{{"ai-key", "AIKEY-abcdefgh234567"}},
+		},
+		{
+			"several blank lines",
+			"Fix\n\n\n\nAI-Key: AIKEY-abcdefgh234567\n",
+			[]Trailer{{"AI-Key", "AIKEY-abcdefgh234567"}},
+		},
+		{
+			"continuation line",
+			"Fix\n\nNote: a long\n  value\n",
+			[]Trailer{{"Note", "a long value"}},
+		},
+		{
+			// A trailer-like line outside the last paragraph is body text.
+			"not in the last paragraph",
+			"Fix\n\nAI-Key: AIKEY-abcdefgh234567\n\nMore body text.\n",
+			nil,
+		},
+		{
+			"last paragraph not all trailers",
+			"Fix\n\nAI-Key: AIKEY-abcdefgh234567\nand some prose\n",
+			nil,
+		},
+		{"no space in the token", "Fix\n\nAI Key: AIKEY-abcdefgh234567\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseTrailers(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTrailers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitKey(t *testing.T) {
+	const key = "AIKEY-abcdefgh234567"
+	tests := []struct {
+		message, want string
+	}{
+		{"Fix\n\nAI-Key: " + key + "\n", "trailer"},
+		{"Fix\n\nai-key: " + key + "\n", "trailer"},
+		{"Fix\n\nAI-Key: " + key + "\n\nmore\n", "message"},
+		{"Fix, key " + key, "message"},
+		{"Fix\n\nAI-Key: AIKEY-otherkey2345678\n", ""},
+		{"Fix", ""},
+	}
+	for _, tt := range tests {
+		if got := CommitKey(Commit{Message: tt.message}, key); got != tt.want {
+			t.Errorf("CommitKey(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+// Merge commits are left out of the submission, so a key in a merge
+// message does not cover the files the merge brings in.
+func TestKeyedByCommits(t *testing.T) {
+	const key = "AIKEY-abcdefgh234567"
+	r := NewFakeRepo()
+	keyFile := map[string]string{"key.agents_.md": "Key: " + key + "\n"}
+	with := func(files map[string]string) map[string]string {
+		out := map[string]string{"key.agents_.md": keyFile["key.agents_.md"]}
+		for p, c := range files {
+			out[p] = c
+		}
+		return out
+	}
+	anchor := r.Commit("init", keyFile)
+	side := r.Commit("side", with(map[string]string{"side.go": "package side\n"}), anchor)
+	r.SetRef("HEAD", anchor)
+	keyed := r.Commit("keyed\n\nAI-Key: "+key+"\n", with(map[string]string{"a.go": "package a\n", "b.go": "package b\n"}))
+	unkeyed := r.Commit("unkeyed", with(map[string]string{"a.go": "package a\n", "b.go": "package b\n\nfunc B() {}\n", "c.go": "package c\n"}))
+	r.Commit("Merge side\n\nAI-Key: "+key+"\n", with(map[string]string{"a.go": "package a\n", "b.go": "package b\n\nfunc B() {}\n", "c.go": "package c\n", "side.go": "package side\n"}), unkeyed, side)
+
+	commits, err := SubmissionCommits(r, anchor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, c := range commits {
+		messages = append(messages, strings.SplitN(c.Message, "\n", 2)[0])
+	}
+	if want := []string{"unkeyed", "keyed", "side"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("SubmissionCommits = %q, want %q (merges left out)", messages, want)
+	}
+	got := map[string]string{}
+	for p, c := range KeyedByCommits(commits, key) {
+		got[p] = c.SHA
+	}
+	if want := map[string]string{"a.go": keyed, "b.go": keyed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("KeyedByCommits = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package keyguard
+
+import "bytes"
+
+// maxDiffEdits bounds the Myers search in addedLines.  Past it the
+// remaining middle section is reported as rewritten wholesale, which is
+// what a human reviewer would make of a diff that large anyway.
+const maxDiffEdits = 1000
+
+// addedLines diffs before and after line by line and returns the runs of
+// lines after adds, like the "+" side of `git diff -U0`.
+func addedLines(before, after []byte) []Hunk {
+	a, b := splitLines(before), splitLines(after)
+
+	// Trim the common prefix and suffix; most edits are small and local.
+	pre := 0
+	for pre < len(a) && pre < len(b) && bytes.Equal(a[pre], b[pre]) {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && bytes.Equal(a[len(a)-1-suf], b[len(b)-1-suf]) {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	added := make([]bool, len(b))
+	if !myers(a, b, added) {
+		for i := range added {
+			added[i] = true
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(added); i++ {
+		if !added[i] {
+			continue
+		}
+		start := i
+		for i < len(added) && added[i] {
+			i++
+		}
+		hunks = append(hunks, Hunk{Start: pre + start + 1, Count: i - start})
+	}
+	return hunks
+}
+
+// myers marks in added the lines of b that a shortest edit script from a
+// inserts.  It reports false if that script needs more than maxDiffEdits
+// edits.
+func myers(a, b [][]byte, added []bool) bool {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD > maxDiffEdits {
+		maxD = maxDiffEdits
+	}
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // right: deletion
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[x], b[y]) {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				backtrack(trace, offset, n, m, added)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backtrack walks the saved V arrays from (n, m) back to the origin and
+// marks the insertions.
+func backtrack(trace [][]int, offset, x, y int, added []bool) {
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			added[prevY] = true // insertion of b[prevY]
+		}
+		x, y = prevX, prevY
+	}
+	// d == 0: only the diagonal remains.
+}
+
+// splitLines splits data into lines, keeping a final line without a
+// trailing newline.
+func splitLines(data []byte) [][]byte {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
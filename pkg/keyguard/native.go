@@ -0,0 +1,544 @@
+package keyguard
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NativeRepo is a GitRepo that reads the .git directory in-process: loose
+// objects, version 2 pack files (including deltas), loose and packed refs,
+// alternates, linked worktrees and shallow clones.  SHA-256 and reftable
+// repositories, partial clones and other repository extensions are not
+// supported: OpenNative, or a later call that meets one, fails with
+// ErrUnsupported.
+type NativeRepo struct {
+	objectRepo
+	root string
+}
+
+// OpenNative opens the repository whose work tree is root.
+func OpenNative(root string) (*NativeRepo, error) {
+	gitDir, err := findGitDir(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &nativeStore{gitDir: gitDir, commonDir: gitDir}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		dir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(gitDir, dir)
+		}
+		s.commonDir = dir
+	}
+	if cfg, err := os.ReadFile(filepath.Join(s.commonDir, "config")); err == nil {
+		if err := checkConfig(string(cfg)); err != nil {
+			return nil, fmt.Errorf("keyguard: %s: %w", root, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.commonDir, "reftable")); err == nil {
+		return nil, fmt.Errorf("keyguard: %s: reftable refs: %w", root, ErrUnsupported)
+	}
+	if err := s.load(); err != nil {
+		s.close()
+		return nil, err
+	}
+	return &NativeRepo{objectRepo: objectRepo{store: s}, root: root}, nil
+}
+
+// Fetch updates origin/<ref> with the git binary (the network protocol is
+// out of scope here) and picks up the packs it wrote.
+func (r *NativeRepo) Fetch(ref string) error {
+	if err := (&ExecRepo{Root: r.root}).Fetch(ref); err != nil {
+		return err
+	}
+	return r.store.(*nativeStore).load()
+}
+
+// Close closes the pack files.  The repository cannot be read afterwards.
+func (r *NativeRepo) Close() error {
+	return r.store.(*nativeStore).close()
+}
+
+// supportedExtensions are the extensions.* config keys (lower-cased) whose
+// values NativeRepo can read with; any other extension changes the
+// repository format in ways it does not know.
+var supportedExtensions = map[string]func(value string) bool{
+	"objectformat":    func(v string) bool { return strings.EqualFold(v, "sha1") },
+	"refstorage":      func(v string) bool { return strings.EqualFold(v, "files") },
+	"worktreeconfig":  func(string) bool { return true },
+	"preciousobjects": func(string) bool { return true },
+	"noop":            func(string) bool { return true },
+}
+
+// checkConfig rejects repository configs that use an extension NativeRepo
+// does not support, or that make the clone partial (objects fetched from a
+// promisor remote on demand, so a missing object is not a missing file).
+func checkConfig(cfg string) error {
+	section := ""
+	for _, line := range nonEmptyLines(cfg) {
+		line = strings.TrimSpace(line)
+		if line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			name, _, _ := strings.Cut(strings.Trim(line, "[]"), " ")
+			section = strings.ToLower(name)
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			val = "true" // a bare key is a true boolean
+		}
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.Trim(strings.TrimSpace(val), `"`)
+		switch {
+		case section == "extensions":
+			if ok := supportedExtensions[key]; ok == nil || !ok(val) {
+				return fmt.Errorf("extensions.%s = %s: %w", key, val, ErrUnsupported)
+			}
+		case section == "remote" && key == "promisor" && strings.EqualFold(val, "true"):
+			return fmt.Errorf("partial clone: %w", ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// findGitDir returns root/.git, following a "gitdir: <path>" file as used
+// by linked worktrees and submodules.
+func findGitDir(root string) (string, error) {
+	dotGit := filepath.Join(root, ".git")
+	fi, err := os.Stat(dotGit)
+	if err != nil {
+		return "", fmt.Errorf("keyguard: %s is not a git work tree: %w", root, err)
+	}
+	if fi.IsDir() {
+		return dotGit, nil
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", fmt.Errorf("keyguard: read %s: %w", dotGit, err)
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("keyguard: %s: not a gitdir file", dotGit)
+	}
+	dir = strings.TrimSpace(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// nativeStore reads objects and refs from a .git directory.
+type nativeStore struct {
+	gitDir    string // per-worktree dir (HEAD, worktree refs)
+	commonDir string // shared dir (objects, refs, packed-refs)
+
+	mu         sync.Mutex
+	objectDirs []string
+	packs      []*pack
+	packed     map[string]string // packed-refs
+	shallows   map[string]bool
+	cache      map[string]cachedObject
+}
+
+type cachedObject struct {
+	kind string
+	data []byte
+}
+
+// maxCachedObjects bounds the object cache, which mostly serves delta
+// bases and trees revisited by diffs and walks.
+const maxCachedObjects = 4096
+
+// load (re)reads the pack list, packed-refs and shallow list.
+func (s *nativeStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closePacks()
+	s.cache = map[string]cachedObject{}
+
+	s.objectDirs = []string{filepath.Join(s.commonDir, "objects")}
+	if data, err := os.ReadFile(filepath.Join(s.objectDirs[0], "info", "alternates")); err == nil {
+		for _, line := range nonEmptyLines(string(data)) {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(s.objectDirs[0], line)
+			}
+			s.objectDirs = append(s.objectDirs, line)
+		}
+	}
+	for _, dir := range s.objectDirs {
+		if promisors, _ := filepath.Glob(filepath.Join(dir, "pack", "*.promisor")); len(promisors) > 0 {
+			return fmt.Errorf("keyguard: %s: partial clone: %w", dir, ErrUnsupported)
+		}
+		idxs, _ := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		sort.Strings(idxs)
+		for _, idx := range idxs {
+			p, err := openPack(idx)
+			if err != nil {
+				return err
+			}
+			s.packs = append(s.packs, p)
+		}
+	}
+
+	s.packed = map[string]string{}
+	if data, err := os.ReadFile(filepath.Join(s.commonDir, "packed-refs")); err == nil {
+		for _, line := range nonEmptyLines(string(data)) {
+			if line[0] == '#' || line[0] == '^' {
+				continue
+			}
+			if sha, name, ok := strings.Cut(line, " "); ok {
+				s.packed[name] = sha
+			}
+		}
+	}
+
+	s.shallows = map[string]bool{}
+	if data, err := os.ReadFile(filepath.Join(s.commonDir, "shallow")); err == nil {
+		for _, sha := range nonEmptyLines(string(data)) {
+			s.shallows[sha] = true
+		}
+	}
+	return nil
+}
+
+func (s *nativeStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closePacks()
+}
+
+// closePacks closes and forgets the open packs; s.mu must be held.
+func (s *nativeStore) closePacks() error {
+	var first error
+	for _, p := range s.packs {
+		if err := p.f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	s.packs = nil
+	return first
+}
+
+func (s *nativeStore) shallow(sha string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shallows[sha]
+}
+
+func (s *nativeStore) ref(name string) (string, error) {
+	for depth := 0; depth < 10; depth++ {
+		dir := s.commonDir
+		if name == "HEAD" || strings.HasPrefix(name, "refs/worktree/") || strings.HasPrefix(name, "refs/bisect/") {
+			dir = s.gitDir
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			s.mu.Lock()
+			sha, ok := s.packed[name]
+			s.mu.Unlock()
+			if ok {
+				return sha, nil
+			}
+			return "", fmt.Errorf("keyguard: ref %s: %w", name, ErrNotFound)
+		}
+		val := strings.TrimSpace(string(data))
+		target, symbolic := strings.CutPrefix(val, "ref:")
+		if !symbolic {
+			if !isSHA(val) {
+				return "", fmt.Errorf("keyguard: ref %s: malformed content %q", name, val)
+			}
+			return val, nil
+		}
+		name = strings.TrimSpace(target)
+	}
+	return "", fmt.Errorf("keyguard: ref %s: symbolic ref loop", name)
+}
+
+func (s *nativeStore) object(sha string) (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objectLocked(sha)
+}
+
+func (s *nativeStore) objectLocked(sha string) (string, []byte, error) {
+	if o, ok := s.cache[sha]; ok {
+		return o.kind, o.data, nil
+	}
+	kind, data, err := s.read(sha)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(s.cache) >= maxCachedObjects {
+		s.cache = map[string]cachedObject{}
+	}
+	s.cache[sha] = cachedObject{kind, data}
+	return kind, data, nil
+}
+
+func (s *nativeStore) read(sha string) (string, []byte, error) {
+	if !isSHA(sha) {
+		return "", nil, fmt.Errorf("keyguard: object %q: %w", sha, ErrNotFound)
+	}
+	for _, dir := range s.objectDirs {
+		f, err := os.Open(filepath.Join(dir, sha[:2], sha[2:]))
+		if err != nil {
+			continue
+		}
+		kind, data, err := readLoose(f)
+		f.Close()
+		if err != nil {
+			return "", nil, fmt.Errorf("keyguard: loose object %s: %w", sha, err)
+		}
+		return kind, data, nil
+	}
+	raw, _ := hex.DecodeString(sha)
+	for _, p := range s.packs {
+		if off, ok := p.find(raw); ok {
+			kind, data, err := s.readPacked(p, off)
+			if err != nil {
+				return "", nil, fmt.Errorf("keyguard: object %s in %s: %w", sha, filepath.Base(p.f.Name()), err)
+			}
+			return kind, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("keyguard: object %s: %w", sha, ErrNotFound)
+}
+
+// readLoose inflates a loose object: "<type> <size>\0<content>".
+func readLoose(r io.Reader) (string, []byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+	header, body, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", nil, fmt.Errorf("missing header")
+	}
+	kind, _, _ := strings.Cut(string(header), " ")
+	return kind, body, nil
+}
+
+// pack is an open pack file with its version 2 index.
+type pack struct {
+	f       *os.File
+	fanout  [256]uint32
+	shas    []byte // sorted 20-byte names
+	offsets []byte // 4-byte offsets, MSB set → index into large
+	large   []byte // 8-byte offsets
+}
+
+func openPack(idxPath string) (*pack, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("keyguard: read %s: %w", idxPath, err)
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("keyguard: %s: pack index version: %w", idxPath, ErrUnsupported)
+	}
+	p := &pack{}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(idx) < pos+n*(20+4+4) {
+		return nil, fmt.Errorf("keyguard: %s: truncated pack index", idxPath)
+	}
+	p.shas = idx[pos : pos+20*n]
+	pos += 20*n + 4*n // skip CRCs
+	p.offsets = idx[pos : pos+4*n]
+	p.large = idx[pos+4*n:]
+	if p.f, err = os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack"); err != nil {
+		return nil, fmt.Errorf("keyguard: open pack: %w", err)
+	}
+	return p, nil
+}
+
+// find returns the pack offset of the object named raw (20 bytes).
+func (p *pack) find(raw []byte) (int64, bool) {
+	lo := 0
+	if raw[0] > 0 {
+		lo = int(p.fanout[raw[0]-1])
+	}
+	hi := int(p.fanout[raw[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.shas[20*(lo+i):20*(lo+i)+20], raw) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.shas[20*i:20*i+20], raw) {
+		return 0, false
+	}
+	off := binary.BigEndian.Uint32(p.offsets[4*i:])
+	if off&0x80000000 == 0 {
+		return int64(off), true
+	}
+	j := int(off &^ 0x80000000)
+	if len(p.large) < 8*j+8 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[8*j:])), true
+}
+
+var packKinds = map[byte]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+const (
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// readPacked reads and, for deltas, resolves the entry at off.
+func (s *nativeStore) readPacked(p *pack, off int64) (string, []byte, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.f, off, 1<<62))
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	typ := (c >> 4) & 7
+	for c&0x80 != 0 { // the size varint; the inflated data implies it
+		if c, err = r.ReadByte(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var (
+		baseKind string
+		base     []byte
+	)
+	switch typ {
+	case packOfsDelta:
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			rel = (rel+1)<<7 | int64(c&0x7f)
+		}
+		if baseKind, base, err = s.readPacked(p, off-rel); err != nil {
+			return "", nil, err
+		}
+	case packRefDelta:
+		raw := make([]byte, 20)
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return "", nil, err
+		}
+		if baseKind, base, err = s.objectLocked(hex.EncodeToString(raw)); err != nil {
+			return "", nil, err
+		}
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+	if base == nil && typ != packOfsDelta && typ != packRefDelta {
+		kind, ok := packKinds[typ]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown pack object type %d", typ)
+		}
+		return kind, data, nil
+	}
+	out, err := applyDelta(base, data)
+	return baseKind, out, err
+}
+
+// applyDelta rebuilds an object from its base and a git delta: source and
+// target sizes, then copy (from base) and insert (literal) instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	varint := func() (int, error) {
+		n, shift := 0, 0
+		for {
+			if len(delta) == 0 {
+				return 0, fmt.Errorf("truncated delta")
+			}
+			c := delta[0]
+			delta = delta[1:]
+			n |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return n, nil
+			}
+		}
+	}
+	srcSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size %d, want %d", len(base), srcSize)
+	}
+	dstSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		if op&0x80 == 0 {
+			n := int(op)
+			if n == 0 || n > len(delta) {
+				return nil, fmt.Errorf("bad delta insert")
+			}
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+		var offset, size int
+		for i := 0; i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta")
+				}
+				offset |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if op&(0x10<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta")
+				}
+				size |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, fmt.Errorf("delta copy out of range")
+		}
+		out = append(out, base[offset:offset+size]...)
+	}
+	if len(out) != dstSize {
+		return nil, fmt.Errorf("delta produced %d bytes, want %d", len(out), dstSize)
+	}
+	return out, nil
+}
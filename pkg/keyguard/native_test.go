@@ -0,0 +1,83 @@
+package keyguard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeGitDir writes a minimal .git directory with the given config.
+func fakeGitDir(t *testing.T, config string) string {
+	t.Helper()
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	for _, dir := range []string{"objects/pack", "refs/heads"} {
+		if err := os.MkdirAll(filepath.Join(gitDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range map[string]string{"HEAD": "ref: refs/heads/main\n", "config": config} {
+		if err := os.WriteFile(filepath.Join(gitDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestOpenNativeUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		ok     bool
+	}{
+		{"plain", "[core]\n\trepositoryformatversion = 0\n\tbare = false\n", true},
+		{"worktree config", "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tworktreeConfig = true\n", true},
+		{"sha1", "[extensions]\n\tobjectFormat = sha1\n", true},
+		{"sha256", "[extensions]\n\tobjectformat = sha256\n", false},
+		{"reftable", "[extensions]\n\trefStorage = reftable\n", false},
+		{"unknown extension", "[extensions]\n\tsomethingNew = true\n", false},
+		{"partial clone", "[remote \"origin\"]\n\turl = https://example.com/r.git\n\tpromisor = true\n\tpartialclonefilter = blob:none\n", false},
+		{"legacy partial clone", "[extensions]\n\tpartialClone = origin\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenNative(fakeGitDir(t, tt.config))
+			if tt.ok && err != nil {
+				t.Errorf("OpenNative: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrUnsupported) {
+				t.Errorf("OpenNative error = %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+func TestOpenNativeReftableDir(t *testing.T) {
+	root := fakeGitDir(t, "")
+	if err := os.Mkdir(filepath.Join(root, ".git", "reftable"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenNative(root); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("OpenNative error = %v, want ErrUnsupported", err)
+	}
+}
+
+// An object the tree refers to but the store lacks (a blob a partial clone
+// has not fetched) must not read as a missing file.
+func TestMissingObjectIsUnsupported(t *testing.T) {
+	r := NewFakeRepo()
+	head := r.Commit("init", map[string]string{"key.agents_.md": "Key: AIKEY-abcdefgh234567\n"})
+	blob := r.mem.put("blob", []byte("Key: AIKEY-abcdefgh234567\n"))
+	delete(r.mem.objects, blob)
+
+	if _, err := r.ReadFile(head, "key.agents_.md"); !errors.Is(err, ErrUnsupported) || errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadFile error = %v, want ErrUnsupported", err)
+	}
+	if key, err := ReadKeyAtCommit(r, head); err == nil {
+		t.Errorf("ReadKeyAtCommit = %q, nil; want an error", key)
+	}
+	if _, err := r.ReadFile(head, "other.md"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadFile of an absent path: error = %v, want ErrNotFound", err)
+	}
+}
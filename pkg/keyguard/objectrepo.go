@@ -0,0 +1,708 @@
+package keyguard
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// objectStore is the storage layer under objectRepo: raw git objects and
+// refs, from a .git directory (nativeStore) or from memory (FakeRepo).
+type objectStore interface {
+	// object returns the type ("commit", "tree", "blob", "tag") and
+	// content of the object sha, or an error wrapping ErrNotFound.
+	object(sha string) (kind string, data []byte, err error)
+	// ref returns what the fully qualified ref name (or "HEAD") points at,
+	// following symbolic refs, or an error wrapping ErrNotFound.
+	ref(name string) (string, error)
+	// shallow reports whether sha is a shallow-clone boundary, whose
+	// parents are absent.
+	shallow(sha string) bool
+}
+
+// objectRepo implements GitRepo over an objectStore by parsing commits and
+// trees and diffing them itself.
+type objectRepo struct {
+	store objectStore
+}
+
+// commitObject is a parsed commit.
+type commitObject struct {
+	tree    string
+	parents []string
+	time    int64 // committer timestamp
+	message string
+}
+
+// treeEntry is a file in a flattened tree.
+type treeEntry struct {
+	mode string
+	sha  string
+}
+
+func (r *objectRepo) Resolve(rev string) (string, error) {
+	base, ops := rev, ""
+	if i := strings.IndexAny(rev, "^~"); i >= 0 {
+		base, ops = rev[:i], rev[i:]
+	}
+	sha, err := r.resolveName(base)
+	if err != nil {
+		return "", err
+	}
+	if sha, err = r.peel(sha); err != nil {
+		return "", err
+	}
+	for ops != "" {
+		op := ops[0]
+		ops = ops[1:]
+		j := 0
+		for j < len(ops) && ops[j] >= '0' && ops[j] <= '9' {
+			j++
+		}
+		n := 1
+		if j > 0 {
+			n, _ = strconv.Atoi(ops[:j])
+		}
+		ops = ops[j:]
+		if op != '^' && op != '~' {
+			return "", fmt.Errorf("keyguard: unsupported revision %q", rev)
+		}
+		if op == '^' && n == 0 {
+			continue
+		}
+		steps, parent := n, 0 // ~N: first parent N times; ^N: Nth parent once
+		if op == '^' {
+			steps, parent = 1, n-1
+		}
+		for ; steps > 0; steps-- {
+			c, err := r.commit(sha)
+			if err != nil {
+				return "", err
+			}
+			if parent >= len(c.parents) {
+				return "", fmt.Errorf("keyguard: resolve %s: %w", rev, ErrNotFound)
+			}
+			sha = c.parents[parent]
+		}
+	}
+	return sha, nil
+}
+
+// resolveName looks up a SHA or ref name the way git's rev-parse does.
+func (r *objectRepo) resolveName(name string) (string, error) {
+	if isSHA(name) {
+		if _, _, err := r.store.object(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	candidates := []string{name}
+	if name != "HEAD" && !strings.HasPrefix(name, "refs/") {
+		candidates = []string{"refs/" + name, "refs/tags/" + name, "refs/heads/" + name,
+			"refs/remotes/" + name, "refs/remotes/" + name + "/HEAD"}
+	}
+	for _, c := range candidates {
+		if sha, err := r.store.ref(c); err == nil {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("keyguard: resolve %s: %w", name, ErrNotFound)
+}
+
+// object reads an object that a ref or another object refers to.  Its
+// absence means the clone is partial (or damaged), not that a file or
+// revision does not exist, so it is reported as ErrUnsupported rather than
+// ErrNotFound.
+func (r *objectRepo) object(sha string) (string, []byte, error) {
+	kind, data, err := r.store.object(sha)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil, fmt.Errorf("keyguard: object %s is missing from the object store: %w", sha, ErrUnsupported)
+	}
+	return kind, data, err
+}
+
+// peel follows annotated tags to the commit they name.
+func (r *objectRepo) peel(sha string) (string, error) {
+	for {
+		kind, data, err := r.object(sha)
+		if err != nil {
+			return "", err
+		}
+		switch kind {
+		case "commit":
+			return sha, nil
+		case "tag":
+			obj, _, _ := bytes.Cut(bytes.TrimPrefix(data, []byte("object ")), []byte("\n"))
+			sha = string(obj)
+		default:
+			return "", fmt.Errorf("keyguard: %s is a %s, not a commit", sha, kind)
+		}
+	}
+}
+
+func (r *objectRepo) commit(sha string) (*commitObject, error) {
+	kind, data, err := r.object(sha)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "commit" {
+		return nil, fmt.Errorf("keyguard: %s is a %s, not a commit", sha, kind)
+	}
+	header, msg, _ := bytes.Cut(data, []byte("\n\n"))
+	c := &commitObject{message: string(msg)}
+	for _, line := range strings.Split(string(header), "\n") {
+		key, val, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			c.tree = val
+		case "parent":
+			c.parents = append(c.parents, val)
+		case "committer":
+			// "Name <email> 1700000000 +0000"
+			if f := strings.Fields(val); len(f) >= 2 {
+				c.time, _ = strconv.ParseInt(f[len(f)-2], 10, 64)
+			}
+		}
+	}
+	if r.store.shallow(sha) {
+		c.parents = nil
+	}
+	return c, nil
+}
+
+// files flattens commit's tree into path → entry.  Submodule entries
+// (gitlinks) are left out: they have no content in this repository.
+func (r *objectRepo) files(commit string) (map[string]treeEntry, error) {
+	c, err := r.commit(commit)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]treeEntry{}
+	return out, r.walkTree(c.tree, "", out)
+}
+
+func (r *objectRepo) walkTree(sha, prefix string, out map[string]treeEntry) error {
+	entries, err := r.tree(sha)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		switch {
+		case e.mode == "40000":
+			if err := r.walkTree(e.sha, prefix+e.name+"/", out); err != nil {
+				return err
+			}
+		case e.mode == "160000":
+		default:
+			out[prefix+e.name] = treeEntry{mode: e.mode, sha: e.sha}
+		}
+	}
+	return nil
+}
+
+type namedEntry struct {
+	name, mode, sha string
+}
+
+// tree parses the tree object sha: entries of "<mode> <name>\0<20-byte sha>".
+func (r *objectRepo) tree(sha string) ([]namedEntry, error) {
+	kind, data, err := r.object(sha)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "tree" {
+		return nil, fmt.Errorf("keyguard: %s is a %s, not a tree", sha, kind)
+	}
+	var entries []namedEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+21 {
+			return nil, fmt.Errorf("keyguard: corrupt tree %s", sha)
+		}
+		entries = append(entries, namedEntry{
+			mode: string(data[:sp]),
+			name: string(data[sp+1 : nul]),
+			sha:  fmt.Sprintf("%x", data[nul+1:nul+21]),
+		})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+func (r *objectRepo) ReadFile(commit, file string) ([]byte, error) {
+	e, err := r.entry(commit, file)
+	if err != nil {
+		return nil, err
+	}
+	kind, data, err := r.object(e.sha)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "blob" {
+		return nil, fmt.Errorf("keyguard: %s:%s: %w", commit, file, ErrNotFound)
+	}
+	return data, nil
+}
+
+func (r *objectRepo) Mode(commit, file string) (string, error) {
+	e, err := r.entry(commit, file)
+	if err != nil {
+		return "", err
+	}
+	return e.mode, nil
+}
+
+// entry finds file in commit's tree.
+func (r *objectRepo) entry(commit, file string) (namedEntry, error) {
+	sha, err := r.Resolve(commit)
+	if err != nil {
+		return namedEntry{}, err
+	}
+	c, err := r.commit(sha)
+	if err != nil {
+		return namedEntry{}, err
+	}
+	e := namedEntry{mode: "40000", sha: c.tree}
+	for _, part := range strings.Split(path.Clean(file), "/") {
+		if e.mode != "40000" {
+			return namedEntry{}, fmt.Errorf("keyguard: %s:%s: %w", commit, file, ErrNotFound)
+		}
+		entries, err := r.tree(e.sha)
+		if err != nil {
+			return namedEntry{}, err
+		}
+		found := false
+		for _, te := range entries {
+			if te.name == part {
+				e, found = te, true
+				break
+			}
+		}
+		if !found {
+			return namedEntry{}, fmt.Errorf("keyguard: %s:%s: %w", commit, file, ErrNotFound)
+		}
+	}
+	return e, nil
+}
+
+func (r *objectRepo) ListFiles(commit string) ([]string, error) {
+	sha, err := r.Resolve(commit)
+	if err != nil {
+		return nil, err
+	}
+	files, err := r.files(sha)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(files), nil
+}
+
+func (r *objectRepo) ChangedFiles(from, to string) ([]string, error) {
+	changes, err := r.diffTrees(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, c := range changes {
+		if c.cur.sha != "" {
+			changed = append(changed, c.path)
+		}
+	}
+	return changed, nil
+}
+
+func (r *objectRepo) AddedHunks(from, to string) (map[string][]Hunk, error) {
+	changes, err := r.diffTrees(from, to)
+	if err != nil {
+		return nil, err
+	}
+	// A renamed file is diffed against its source.
+	renames, err := r.findRenames(changes)
+	if err != nil {
+		return nil, err
+	}
+	hunks := map[string][]Hunk{}
+	for _, c := range changes {
+		if c.cur.sha == "" {
+			continue
+		}
+		p, e := c.path, c.old
+		if src, ok := renames[p]; ok {
+			e = src
+		}
+		var before []byte
+		if e.sha != "" {
+			if _, before, err = r.object(e.sha); err != nil {
+				return nil, err
+			}
+		}
+		_, after, err := r.object(c.cur.sha)
+		if err != nil {
+			return nil, err
+		}
+		if isBinary(before) || isBinary(after) {
+			hunks[p] = nil
+			continue
+		}
+		hunks[p] = addedLines(before, after)
+	}
+	return hunks, nil
+}
+
+// treeChange is a file that differs between two trees: added (old is the
+// zero entry), deleted (cur is) or modified.
+type treeChange struct {
+	path     string
+	old, cur treeEntry
+}
+
+// diffTrees returns the files that differ from → to, sorted by path.
+func (r *objectRepo) diffTrees(from, to string) ([]treeChange, error) {
+	fromSHA, err := r.Resolve(from)
+	if err != nil {
+		return nil, err
+	}
+	toSHA, err := r.Resolve(to)
+	if err != nil {
+		return nil, err
+	}
+	return r.diffCommits(fromSHA, toSHA)
+}
+
+// diffCommits is diffTrees for two commit SHAs; from may be "" (a root
+// commit's missing parent).
+func (r *objectRepo) diffCommits(from, to string) ([]treeChange, error) {
+	var oldTree string
+	if from != "" {
+		c, err := r.commit(from)
+		if err != nil {
+			return nil, err
+		}
+		oldTree = c.tree
+	}
+	c, err := r.commit(to)
+	if err != nil {
+		return nil, err
+	}
+	var changes []treeChange
+	if err := r.diffTree(oldTree, c.tree, "", &changes); err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes, nil
+}
+
+// diffTree appends the files under prefix that differ between the trees
+// oldTree and curTree ("" for none) to out.  Subtrees with the same hash
+// on both sides are skipped without being read, so the cost is in the size
+// of the change, not of the tree.  Submodule entries are left out, as in
+// files.
+func (r *objectRepo) diffTree(oldTree, curTree, prefix string, out *[]treeChange) error {
+	if oldTree == curTree {
+		return nil
+	}
+	read := func(sha string) (map[string]namedEntry, error) {
+		m := map[string]namedEntry{}
+		if sha == "" {
+			return m, nil
+		}
+		entries, err := r.tree(sha)
+		for _, e := range entries {
+			m[e.name] = e
+		}
+		return m, err
+	}
+	old, err := read(oldTree)
+	if err != nil {
+		return err
+	}
+	cur, err := read(curTree)
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range cur {
+		names[name] = true
+	}
+	for name := range names {
+		o, c := old[name], cur[name]
+		if o == c {
+			continue
+		}
+		// A name can be a directory on one side and a file on the other.
+		var oldSub, curSub string
+		if o.mode == "40000" {
+			oldSub = o.sha
+		}
+		if c.mode == "40000" {
+			curSub = c.sha
+		}
+		if oldSub != "" || curSub != "" {
+			if err := r.diffTree(oldSub, curSub, prefix+name+"/", out); err != nil {
+				return err
+			}
+		}
+		ch := treeChange{path: prefix + name}
+		if isFileMode(o.mode) {
+			ch.old = treeEntry{mode: o.mode, sha: o.sha}
+		}
+		if isFileMode(c.mode) {
+			ch.cur = treeEntry{mode: c.mode, sha: c.sha}
+		}
+		if ch.old != ch.cur {
+			*out = append(*out, ch)
+		}
+	}
+	return nil
+}
+
+// isFileMode reports whether a tree entry mode is a file with content in
+// this repository: not a directory or submodule (nor absent).
+func isFileMode(mode string) bool {
+	return mode != "" && mode != "40000" && mode != "160000"
+}
+
+// isBinary applies git's heuristic: a NUL byte in the first 8000 bytes.
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// Log walks commits newest first by committer date, as git log does,
+// marking everything reachable from from as uninteresting.
+func (r *objectRepo) Log(from, to string) ([]Commit, error) {
+	fromSHA, err := r.Resolve(from)
+	if err != nil {
+		return nil, err
+	}
+	toSHA, err := r.Resolve(to)
+	if err != nil {
+		return nil, err
+	}
+	const uninteresting = 1
+	w := newWalker(r, uninteresting)
+	if err := w.push(fromSHA, uninteresting); err != nil {
+		return nil, err
+	}
+	if err := w.push(toSHA, 0); err != nil {
+		return nil, err
+	}
+	var order []string
+	for w.interesting() {
+		sha, c, flags := w.pop()
+		if flags&uninteresting == 0 {
+			order = append(order, sha)
+		}
+		for _, p := range c.parents {
+			if err := w.push(p, flags); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var commits []Commit
+	for _, sha := range order {
+		if w.flags[sha]&uninteresting != 0 {
+			continue // reached from from after all (clock skew)
+		}
+		c := w.commits[sha]
+		if len(c.parents) > 1 {
+			continue // merges, like git log without -m, show no diff
+		}
+		parent := ""
+		if len(c.parents) == 1 {
+			parent = c.parents[0]
+		}
+		files, err := r.commitFiles(parent, sha)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue // as with git log --diff-filter=ACMR
+		}
+		commits = append(commits, Commit{SHA: sha, Message: strings.TrimSpace(c.message), Files: files})
+	}
+	return commits, nil
+}
+
+// commitFiles lists the paths sha adds or modifies relative to parent
+// ("" for a root commit).
+func (r *objectRepo) commitFiles(parent, sha string) ([]string, error) {
+	changes, err := r.diffCommits(parent, sha)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, c := range changes {
+		if c.cur.sha != "" {
+			files = append(files, c.path)
+		}
+	}
+	return files, nil
+}
+
+func (r *objectRepo) MergeBase(a, b string) (string, error) {
+	aSHA, err := r.Resolve(a)
+	if err != nil {
+		return "", err
+	}
+	bSHA, err := r.Resolve(b)
+	if err != nil {
+		return "", err
+	}
+	// git's paint_down_to_common: paint ancestors of a and of b; a commit
+	// painted both ways is a candidate, and its ancestors are stale.  The
+	// walk continues until only stale commits remain, so candidates that
+	// turn out to be ancestors of other candidates are dropped even when
+	// committer dates do not order them.
+	const fromA, fromB, stale = 1, 2, 4
+	w := newWalker(r, stale)
+	if err := w.push(aSHA, fromA); err != nil {
+		return "", err
+	}
+	if err := w.push(bSHA, fromB); err != nil {
+		return "", err
+	}
+	var candidates []string
+	for w.interesting() {
+		sha, c, flags := w.pop()
+		if flags&stale != 0 {
+			flags |= stale
+		} else if flags&(fromA|fromB) == fromA|fromB {
+			candidates = append(candidates, sha)
+			flags |= stale
+		}
+		for _, p := range c.parents {
+			if err := w.push(p, flags); err != nil {
+				return "", err
+			}
+		}
+	}
+	for _, sha := range candidates {
+		if w.flags[sha]&stale == 0 {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("keyguard: merge-base %s %s: %w", a, b, ErrNotFound)
+}
+
+// walker visits commits newest first by committer date, propagating flags
+// from each commit to its parents.  The walk is over once every queued
+// commit has the done flag.
+type walker struct {
+	r       *objectRepo
+	done    int
+	commits map[string]*commitObject
+	flags   map[string]int
+	seq     map[string]int // first-queued order, breaking date ties as git does
+	queued  map[string]int // queue entries per commit
+	pending int            // queue entries whose commit lacks done
+	queue   []string
+}
+
+func newWalker(r *objectRepo, done int) *walker {
+	return &walker{
+		r:       r,
+		done:    done,
+		commits: map[string]*commitObject{},
+		flags:   map[string]int{},
+		seq:     map[string]int{},
+		queued:  map[string]int{},
+	}
+}
+
+// push adds flags to sha, queueing it the first time it is seen and again
+// whenever it gains a flag, so the flag reaches its parents.
+func (w *walker) push(sha string, flags int) error {
+	if _, seen := w.commits[sha]; seen {
+		if flags&^w.flags[sha] != 0 {
+			w.setFlags(sha, w.flags[sha]|flags)
+			heap.Push(w, sha)
+		}
+		return nil
+	}
+	c, err := w.r.commit(sha)
+	if err != nil {
+		return err
+	}
+	w.commits[sha] = c
+	w.setFlags(sha, flags)
+	w.seq[sha] = len(w.seq)
+	heap.Push(w, sha)
+	return nil
+}
+
+func (w *walker) pop() (string, *commitObject, int) {
+	sha := heap.Pop(w).(string)
+	return sha, w.commits[sha], w.flags[sha]
+}
+
+// setFlags sets sha's flags, keeping pending in step when it gains done.
+func (w *walker) setFlags(sha string, flags int) {
+	if w.flags[sha]&w.done == 0 && flags&w.done != 0 {
+		w.pending -= w.queued[sha]
+	}
+	w.flags[sha] = flags
+}
+
+// interesting reports whether any queued commit lacks the done flag.
+func (w *walker) interesting() bool { return w.pending > 0 }
+
+func (w *walker) Len() int { return len(w.queue) }
+func (w *walker) Less(i, j int) bool {
+	a, b := w.queue[i], w.queue[j]
+	if ta, tb := w.commits[a].time, w.commits[b].time; ta != tb {
+		return ta > tb
+	}
+	return w.seq[a] < w.seq[b]
+}
+func (w *walker) Swap(i, j int) { w.queue[i], w.queue[j] = w.queue[j], w.queue[i] }
+func (w *walker) Push(x any) {
+	sha := x.(string)
+	w.queue = append(w.queue, sha)
+	w.queued[sha]++
+	if w.flags[sha]&w.done == 0 {
+		w.pending++
+	}
+}
+func (w *walker) Pop() any {
+	last := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	w.queued[last]--
+	if w.flags[last]&w.done == 0 {
+		w.pending--
+	}
+	return last
+}
+
+func isSHA(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]treeEntry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,178 @@
+package keyguard
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CommentStyle is how a family of languages writes comments.
+type CommentStyle struct {
+	// Languages names the family, as shown to agents in key.agents_.md.
+	Languages string
+	// Extensions lists the (lower-case) file extensions in the family.
+	Extensions []string
+	// Line starts a comment running to the end of the line ("//", "#").
+	Line string
+	// Open and Close delimit a block comment ("<!--" … "-->").
+	Open, Close string
+}
+
+// Comment renders text as a comment in this style, preferring a line
+// comment.
+func (c CommentStyle) Comment(text string) string {
+	if c.Line != "" {
+		return c.Line + " " + text
+	}
+	return c.Open + " " + text + " " + c.Close
+}
+
+// CommentStyles is the comment syntax table: inject_key shows it to agents
+// and CheckPlacement enforces it.
+var CommentStyles = []CommentStyle{
+	{
+		Languages:  "Go / C / Rust / JS / TS",
+		Extensions: []string{".go", ".c", ".h", ".cc", ".cpp", ".hpp", ".rs", ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx"},
+		Line:       "//", Open: "/*", Close: "*/",
+	},
+	{
+		Languages:  "Python / Shell",
+		Extensions: []string{".py", ".sh", ".bash", ".zsh"},
+		Line:       "#",
+	},
+	{
+		Languages:  "HTML / XML / Markdown",
+		Extensions: []string{".html", ".htm", ".xml", ".svg", ".md", ".markdown"},
+		Open:       "<!--", Close: "-->",
+	},
+	{
+		Languages:  "YAML / TOML / INI",
+		Extensions: []string{".yaml", ".yml", ".toml", ".ini", ".cfg", ".conf"},
+		Line:       "#",
+	},
+}
+
+// CommentStyleFor returns the comment style for path's extension.
+func CommentStyleFor(path string) (CommentStyle, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, c := range CommentStyles {
+		for _, e := range c.Extensions {
+			if e == ext {
+				return c, true
+			}
+		}
+	}
+	return CommentStyle{}, false
+}
+
+// DefaultPlacementLines is how near the top of a file the key must sit.
+const DefaultPlacementLines = 20
+
+// CheckPlacement reports whether key sits in a comment within the first
+// maxLines lines of data, the content of path.  A comment must start its
+// line (after indentation), so a key inside a string literal or after code
+// does not count; block comments may span lines.  Files of an unknown type
+// accept any style in CommentStyles.  When the key is misplaced, reason
+// says how.
+func CheckPlacement(path string, data []byte, key string, maxLines int) (ok bool, reason string) {
+	for _, c := range headerComments(path, data, maxLines) {
+		if strings.Contains(c.text, key) {
+			return true, ""
+		}
+	}
+	at := bytes.Index(data, []byte(key))
+	if at < 0 {
+		return false, "key not found"
+	}
+	first := bytes.Count(data[:at], []byte("\n")) + 1
+	if first > maxLines {
+		return false, fmt.Sprintf("key first appears on line %d, past the first %d lines", first, maxLines)
+	}
+	return false, fmt.Sprintf("key on line %d is not in a comment", first)
+}
+
+// headerComment is the comment on one line near the top of a file.
+type headerComment struct {
+	line int    // 1-based
+	text string // the commented part of the line
+}
+
+// headerComments returns the comments within the first maxLines lines of
+// data, the content of path, as CheckPlacement recognizes them.
+func headerComments(path string, data []byte, maxLines int) []headerComment {
+	styles := CommentStyles
+	if c, found := CommentStyleFor(path); found {
+		styles = []CommentStyle{c}
+	}
+	var comments []headerComment
+	var open *CommentStyle // block comment still open at the start of a line
+	for i, line := range strings.SplitN(string(data), "\n", maxLines+1) {
+		if i == maxLines {
+			break
+		}
+		n := i + 1
+		if open != nil {
+			if end := strings.Index(line, open.Close); end >= 0 {
+				line, open = line[:end], nil
+			}
+			comments = append(comments, headerComment{n, line})
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		for j := range styles {
+			c := &styles[j]
+			if c.Line != "" && strings.HasPrefix(trimmed, c.Line) {
+				comments = append(comments, headerComment{n, trimmed[len(c.Line):]})
+				break
+			}
+			if c.Open != "" && strings.HasPrefix(trimmed, c.Open) {
+				text := trimmed[len(c.Open):]
+				if end := strings.Index(text, c.Close); end >= 0 {
+					text = text[:end]
+				} else {
+					open = c
+				}
+				comments = append(comments, headerComment{n, text})
+				break
+			}
+		}
+	}
+	return comments
+}
+
+// PlacementMode is what check_ai_key does with a misplaced key.
+type PlacementMode string
+
+const (
+	PlacementOff   PlacementMode = "off"   // any occurrence counts
+	PlacementWarn  PlacementMode = "warn"  // counts, with a warning
+	PlacementError PlacementMode = "error" // does not count
+)
+
+// PlacementFromEnv reads
+//
+//	AI_KEY_PLACEMENT        off | warn (default) | error
+//	AI_KEY_PLACEMENT_LINES  how many leading lines may hold the key
+//	                        (default DefaultPlacementLines)
+func PlacementFromEnv() (PlacementMode, int, error) {
+	mode := PlacementWarn
+	switch v := PlacementMode(strings.ToLower(strings.TrimSpace(os.Getenv("AI_KEY_PLACEMENT")))); v {
+	case "":
+	case PlacementOff, PlacementWarn, PlacementError:
+		mode = v
+	default:
+		return "", 0, fmt.Errorf("keyguard: unknown AI_KEY_PLACEMENT %q (valid: off, warn, error)", v)
+	}
+	lines := DefaultPlacementLines
+	if v := strings.TrimSpace(os.Getenv("AI_KEY_PLACEMENT_LINES")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return "", 0, fmt.Errorf("keyguard: invalid AI_KEY_PLACEMENT_LINES %q", v)
+		}
+		lines = n
+	}
+	return mode, lines, nil
+}
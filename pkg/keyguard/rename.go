@@ -0,0 +1,160 @@
+package keyguard
+
+import (
+	"path"
+	"sort"
+)
+
+// renameLimit caps the deleted × added file pairs compared for inexact
+// renames, as git's diff.renameLimit does; past it only identical content
+// is paired.
+const renameLimit = 1000
+
+// minRenameScore is git's default rename threshold (-M50%).
+const minRenameScore = 0.5
+
+// emptyBlob is the SHA of the empty file, which git never pairs.
+const emptyBlob = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// findRenames pairs added files with deleted ones as git's rename
+// detection does, returning the source of each renamed path.  Identical
+// content pairs first, preferring a source with the same file name.  The
+// rest pair by similarity, best score first, each source used once; binary
+// files are not compared.
+func (r *objectRepo) findRenames(changes []treeChange) (map[string]treeEntry, error) {
+	var srcs, dsts []treeChange
+	for _, c := range changes {
+		switch {
+		case c.cur.sha == "" && c.old.sha != emptyBlob:
+			srcs = append(srcs, c)
+		case c.old.sha == "" && c.cur.sha != emptyBlob:
+			dsts = append(dsts, c)
+		}
+	}
+	renames := map[string]treeEntry{}
+	if len(srcs) == 0 || len(dsts) == 0 {
+		return renames, nil
+	}
+	used := make([]bool, len(srcs))
+	pair := func(d treeChange, s int) {
+		renames[d.path] = srcs[s].old
+		used[s] = true
+	}
+	sameKind := func(d treeChange, s int) bool {
+		return (d.cur.mode == "120000") == (srcs[s].old.mode == "120000")
+	}
+
+	var rest []treeChange
+	for _, d := range dsts {
+		match := -1
+		for s := range srcs {
+			if used[s] || srcs[s].old.sha != d.cur.sha || !sameKind(d, s) {
+				continue
+			}
+			if match < 0 {
+				match = s
+			}
+			if path.Base(srcs[s].path) == path.Base(d.path) {
+				match = s
+				break
+			}
+		}
+		if match >= 0 {
+			pair(d, match)
+		} else {
+			rest = append(rest, d)
+		}
+	}
+	var left []int
+	for s := range srcs {
+		if !used[s] {
+			left = append(left, s)
+		}
+	}
+	if len(rest) == 0 || len(left) == 0 || len(rest)*len(left) > renameLimit*renameLimit {
+		return renames, nil
+	}
+
+	lines := map[string]map[string]int{} // by SHA; nil for binaries
+	size := map[string]int{}
+	load := func(sha string) (map[string]int, error) {
+		if m, ok := lines[sha]; ok {
+			return m, nil
+		}
+		_, data, err := r.object(sha)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]int
+		if !isBinary(data) {
+			m = map[string]int{}
+			for _, l := range splitLines(data) {
+				m[string(l)]++
+			}
+		}
+		lines[sha], size[sha] = m, len(data)
+		return m, nil
+	}
+	type candidate struct {
+		dst, src int
+		score    float64
+		sameName bool
+	}
+	var candidates []candidate
+	for d, dc := range rest {
+		dl, err := load(dc.cur.sha)
+		if err != nil {
+			return nil, err
+		}
+		if dl == nil {
+			continue
+		}
+		for _, s := range left {
+			if !sameKind(dc, s) {
+				continue
+			}
+			sl, err := load(srcs[s].old.sha)
+			if err != nil {
+				return nil, err
+			}
+			if sl == nil {
+				continue
+			}
+			score := similarity(sl, size[srcs[s].old.sha], dl, size[dc.cur.sha])
+			if score >= minRenameScore {
+				candidates = append(candidates, candidate{d, s, score, path.Base(srcs[s].path) == path.Base(dc.path)})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.sameName && !b.sameName
+	})
+	for _, c := range candidates {
+		if _, done := renames[rest[c.dst].path]; done || used[c.src] {
+			continue
+		}
+		pair(rest[c.dst], c.src)
+	}
+	return renames, nil
+}
+
+// similarity is the share of the larger file's bytes in lines both files
+// have, counting a repeated line as often as it occurs in both.
+func similarity(a map[string]int, aSize int, b map[string]int, bSize int) float64 {
+	larger := max(aSize, bSize)
+	if larger == 0 || float64(min(aSize, bSize)) < minRenameScore*float64(larger) {
+		return 0 // the size gap alone keeps it under the threshold
+	}
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	common := 0
+	for line, n := range a {
+		common += min(n, b[line]) * len(line)
+	}
+	return float64(common) / float64(larger)
+}
@@ -0,0 +1,158 @@
+package keyguard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned (wrapped) by GitRepo methods when a revision,
+// commit or path does not exist, as opposed to the repository being
+// unreadable.
+var ErrNotFound = errors.New("not found")
+
+// ErrUnsupported is returned (wrapped) by NativeRepo when the repository
+// uses a feature it cannot read: a reftable ref store, a partial clone
+// whose objects are fetched on demand, an unknown extension.  Unlike
+// ErrNotFound it says nothing about whether a file exists, so callers must
+// not treat it as absence.
+var ErrUnsupported = errors.New("unsupported repository feature")
+
+// GitRepo is the read-only view of a git repository the key check needs.
+// Revisions are anything Resolve accepts; "HEAD" names the checked-out
+// commit.
+type GitRepo interface {
+	// Resolve returns the commit SHA rev names.  Implementations accept at
+	// least full SHAs, "HEAD", branch, tag and remote-tracking names
+	// ("main", "origin/main") and "^" / "~N" suffixes.
+	Resolve(rev string) (string, error)
+	// MergeBase returns a best common ancestor of a and b.
+	MergeBase(a, b string) (string, error)
+	// ReadFile returns the content of path in commit's tree.  For a
+	// symbolic link that is the link target.
+	ReadFile(commit, path string) ([]byte, error)
+	// Mode returns the git file mode of path in commit's tree: "100644",
+	// "100755", "120000" (symbolic link), "160000" (submodule) or "40000"
+	// (directory).
+	Mode(commit, path string) (string, error)
+	// ListFiles returns every file path in commit's tree.
+	ListFiles(commit string) ([]string, error)
+	// ChangedFiles returns the paths added or modified between from and
+	// to (renamed files under their new name), sorted.
+	ChangedFiles(from, to string) ([]string, error)
+	// AddedHunks returns the hunks of lines to adds relative to from, for
+	// every path ChangedFiles reports.  Binary files map to no hunks.
+	AddedHunks(from, to string) (map[string][]Hunk, error)
+	// Log returns the commits reachable from to but not from from, newest
+	// first, leaving out merges and commits that add or modify no file.
+	Log(from, to string) ([]Commit, error)
+}
+
+// Fetcher is implemented by GitRepos that can update a remote-tracking
+// branch from origin.
+type Fetcher interface {
+	Fetch(ref string) error
+}
+
+// OpenRepo opens the repository at repoRoot with the backend named by
+// AI_KEY_GIT:
+//
+//	exec    the git binary (default)
+//	native  the in-process object reader (NativeRepo), falling back to the
+//	        git binary when the repository cannot be opened natively, or
+//	        when a later call fails with ErrUnsupported
+func OpenRepo(repoRoot string) (GitRepo, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("AI_KEY_GIT"))); v {
+	case "", "exec":
+		return &ExecRepo{Root: repoRoot}, nil
+	case "native":
+		r, err := OpenNative(repoRoot)
+		if err != nil {
+			return &ExecRepo{Root: repoRoot}, nil
+		}
+		return &fallbackRepo{native: r, exec: &ExecRepo{Root: repoRoot}}, nil
+	default:
+		return nil, fmt.Errorf("keyguard: unknown AI_KEY_GIT %q (valid: exec, native)", v)
+	}
+}
+
+// fallbackRepo answers from native, repeating a call with exec when native
+// fails with ErrUnsupported.
+type fallbackRepo struct {
+	native *NativeRepo
+	exec   *ExecRepo
+}
+
+// fallback returns native's result unless it failed with ErrUnsupported,
+// in which case it returns exec's.
+func fallback[T any](native func() (T, error), exec func() (T, error)) (T, error) {
+	v, err := native()
+	if errors.Is(err, ErrUnsupported) {
+		return exec()
+	}
+	return v, err
+}
+
+func (r *fallbackRepo) Resolve(rev string) (string, error) {
+	return fallback(
+		func() (string, error) { return r.native.Resolve(rev) },
+		func() (string, error) { return r.exec.Resolve(rev) })
+}
+
+func (r *fallbackRepo) MergeBase(a, b string) (string, error) {
+	return fallback(
+		func() (string, error) { return r.native.MergeBase(a, b) },
+		func() (string, error) { return r.exec.MergeBase(a, b) })
+}
+
+func (r *fallbackRepo) ReadFile(commit, path string) ([]byte, error) {
+	return fallback(
+		func() ([]byte, error) { return r.native.ReadFile(commit, path) },
+		func() ([]byte, error) { return r.exec.ReadFile(commit, path) })
+}
+
+func (r *fallbackRepo) Mode(commit, path string) (string, error) {
+	return fallback(
+		func() (string, error) { return r.native.Mode(commit, path) },
+		func() (string, error) { return r.exec.Mode(commit, path) })
+}
+
+func (r *fallbackRepo) ListFiles(commit string) ([]string, error) {
+	return fallback(
+		func() ([]string, error) { return r.native.ListFiles(commit) },
+		func() ([]string, error) { return r.exec.ListFiles(commit) })
+}
+
+func (r *fallbackRepo) ChangedFiles(from, to string) ([]string, error) {
+	return fallback(
+		func() ([]string, error) { return r.native.ChangedFiles(from, to) },
+		func() ([]string, error) { return r.exec.ChangedFiles(from, to) })
+}
+
+func (r *fallbackRepo) AddedHunks(from, to string) (map[string][]Hunk, error) {
+	return fallback(
+		func() (map[string][]Hunk, error) { return r.native.AddedHunks(from, to) },
+		func() (map[string][]Hunk, error) { return r.exec.AddedHunks(from, to) })
+}
+
+func (r *fallbackRepo) Log(from, to string) ([]Commit, error) {
+	return fallback(
+		func() ([]Commit, error) { return r.native.Log(from, to) },
+		func() ([]Commit, error) { return r.exec.Log(from, to) })
+}
+
+// Fetch fetches with native, which also rereads the packs the fetch wrote.
+func (r *fallbackRepo) Fetch(ref string) error { return r.native.Fetch(ref) }
+
+func (r *fallbackRepo) Close() error { return r.native.Close() }
+
+// Close releases any resources held by repo, such as NativeRepo's open
+// pack files.  Repositories without resources are left alone.
+func Close(repo GitRepo) error {
+	if c, ok := repo.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
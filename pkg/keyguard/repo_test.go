@@ -0,0 +1,339 @@
+package keyguard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixtureStep changes a work tree and commits it, or runs git commands.
+type fixtureStep struct {
+	write   map[string]string // path → content
+	exec    []string          // paths to make executable
+	symlink map[string]string // path → target
+	remove  []string          // files or directories
+	git     []string          // commands run instead of committing, e.g. "merge feature"
+}
+
+// gitFixture builds a repository with the git binary, one second of
+// committer time per step, so dates order the history as they would in
+// practice.  It skips the test when git is not installed.
+func gitFixture(t *testing.T, steps []fixtureStep) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	git := func(when int, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		date := fmt.Sprintf("%d +0000", 1700000000+when)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com", "GIT_AUTHOR_DATE="+date,
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com", "GIT_COMMITTER_DATE="+date,
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git(0, "init", "-q", "-b", "main")
+	for i, step := range steps {
+		for _, p := range step.remove {
+			if err := os.RemoveAll(filepath.Join(root, p)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for p, content := range step.write {
+			full := filepath.Join(root, p)
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, p := range step.exec {
+			if err := os.Chmod(filepath.Join(root, p), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for p, target := range step.symlink {
+			if err := os.Symlink(target, filepath.Join(root, p)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, c := range step.git {
+			git(i, strings.Fields(c)...)
+		}
+		if step.git == nil {
+			git(i, "add", "-A")
+			git(i, "commit", "-q", "--allow-empty", "-m", fmt.Sprintf("step %d\n\nbody", i))
+		}
+	}
+	return root
+}
+
+// numbered returns n lines "<prefix> <i>".
+func numbered(prefix string, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%s %d\n", prefix, i)
+	}
+	return b.String()
+}
+
+// repoPair opens root with both backends.
+func repoPair(t *testing.T, root string) (native *NativeRepo, git *ExecRepo) {
+	t.Helper()
+	native, err := OpenNative(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { native.Close() })
+	return native, &ExecRepo{Root: root}
+}
+
+// NativeRepo must answer every GitRepo query as the git binary does.
+func TestNativeMatchesExec(t *testing.T) {
+	root := gitFixture(t, []fixtureStep{
+		{
+			write: map[string]string{
+				"a.txt":         numbered("a", 10),
+				"dir/b.go":      numbered("b", 20),
+				"dir/deep/c.go": numbered("c", 5),
+				"same/x.txt":    numbered("x", 30),
+				"tree/t.txt":    "becomes a file\n",
+				" lead.md":      "leading space\n",
+				"ünï\tcode.md":  "unicode and a tab\n",
+				"run.sh":        "#!/bin/sh\n",
+			},
+			exec:    []string{"run.sh"},
+			symlink: map[string]string{"link": "a.txt"},
+		},
+		{git: []string{"tag v1"}},
+		{
+			write:  map[string]string{"a.txt": numbered("a", 12), "dir/new.go": "new\n", "run.sh": "#!/bin/sh\n"},
+			remove: []string{"dir/deep", "run.sh"},
+		},
+		{git: []string{"checkout -q -b feature"}},
+		{
+			write:  map[string]string{"moved.go": numbered("b", 20), "moved2.txt": numbered("x", 30) + "extra\n"},
+			remove: []string{"dir/b.go", "same"},
+		},
+		{remove: []string{" lead.md"}}, // only a deletion
+		{git: []string{"checkout -q main"}},
+		{
+			write:  map[string]string{"tree": "now a file\n"},
+			remove: []string{"tree"},
+		},
+		{git: []string{"merge -q --no-edit feature"}},
+		{write: map[string]string{"after.md": "after the merge\n"}},
+	})
+	native, git := repoPair(t, root)
+
+	for _, rev := range []string{"HEAD", "main", "feature", "v1", "HEAD~1", "HEAD~1^2", "feature~2"} {
+		want, err := git.Resolve(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := native.Resolve(rev); got != want || err != nil {
+			t.Errorf("Resolve(%s) = %s, %v; want %s", rev, got, err, want)
+		}
+		wantFiles, err := git.ListFiles(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := native.ListFiles(rev); !reflect.DeepEqual(got, wantFiles) || err != nil {
+			t.Errorf("ListFiles(%s) = %q, %v; want %q", rev, got, err, wantFiles)
+		}
+	}
+	for _, p := range [][2]string{
+		{"v1", "HEAD"}, {"v1", "feature"}, {"feature", "main"}, {"HEAD~1", "HEAD"},
+		{"feature~2", "feature"}, {"main~1", "feature"}, {"v1", "v1"},
+	} {
+		from, to := p[0], p[1]
+		t.Run(from+".."+to, func(t *testing.T) {
+			want, err := git.ChangedFiles(from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := native.ChangedFiles(from, to); !reflect.DeepEqual(got, want) || err != nil {
+				t.Errorf("ChangedFiles = %q, %v; want %q", got, err, want)
+			}
+			wantHunks, err := git.AddedHunks(from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := native.AddedHunks(from, to); !sameHunks(got, wantHunks) || err != nil {
+				t.Errorf("AddedHunks = %v, %v; want %v", got, err, wantHunks)
+			}
+			wantLog, err := git.Log(from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := native.Log(from, to); !reflect.DeepEqual(got, wantLog) || err != nil {
+				t.Errorf("Log = %+v, %v\nwant %+v", got, err, wantLog)
+			}
+			wantBase, err := git.MergeBase(from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := native.MergeBase(from, to); got != wantBase || err != nil {
+				t.Errorf("MergeBase = %s, %v; want %s", got, err, wantBase)
+			}
+		})
+	}
+}
+
+// Renamed files are diffed against their source when git would pair them:
+// at 50% similarity or more.
+func TestNativeMatchesExecRenames(t *testing.T) {
+	root := gitFixture(t, []fixtureStep{
+		{write: map[string]string{
+			"keep.txt":    numbered("keep", 40),
+			"half.txt":    numbered("half", 10),
+			"little.txt":  numbered("little", 10),
+			"old/name.go": numbered("name", 10),
+			"gone.txt":    numbered("gone", 10),
+			"bin.dat":     "\x00binary\n" + numbered("bin", 10),
+			"twin1.txt":   numbered("twin", 10),
+		}},
+		{
+			write: map[string]string{
+				"kept.txt":    numbered("keep", 40) + "one more\n",
+				"halved.txt":  numbered("half", 10)[len(numbered("half", 4)):] + "new\nnew\nnew\nnew\n",
+				"lost.txt":    numbered("little", 10)[len(numbered("little", 7)):] + "x\ny\nz\nw\nv\nu\nt\n",
+				"new/name.go": numbered("name", 10),
+				"other.txt":   numbered("gone", 3),
+				"bin2.dat":    "\x00binary\n" + numbered("bin", 11),
+				"twin2.txt":   numbered("twin", 10) + "a\n",
+				"twin3.txt":   numbered("twin", 10) + "b\nc\n",
+			},
+			remove: []string{"keep.txt", "half.txt", "little.txt", "old", "gone.txt", "bin.dat", "twin1.txt"},
+		},
+	})
+	native, git := repoPair(t, root)
+	want, err := git.AddedHunks("HEAD~1", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := native.AddedHunks("HEAD~1", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameHunks(got, want) {
+		t.Errorf("AddedHunks = %v\nwant %v", got, want)
+	}
+}
+
+// sameHunks compares AddedHunks results, taking a path with no hunks as
+// absent: git prints no hunk for a binary file or an exact rename.
+func sameHunks(a, b map[string][]Hunk) bool {
+	for p, h := range a {
+		if !reflect.DeepEqual(h, b[p]) && len(h)+len(b[p]) > 0 {
+			return false
+		}
+	}
+	for p, h := range b {
+		if _, ok := a[p]; !ok && len(h) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNativeClose(t *testing.T) {
+	root := gitFixture(t, []fixtureStep{
+		{write: map[string]string{"a.txt": "a\n"}},
+		{git: []string{"gc -q"}},
+	})
+	r, err := OpenNative(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ListFiles("HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Close(r); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := r.ReadFile("HEAD", "a.txt"); err == nil {
+		t.Errorf("ReadFile after Close succeeded")
+	}
+}
+
+func TestLogSkipsMerges(t *testing.T) {
+	root := gitFixture(t, []fixtureStep{
+		{write: map[string]string{"a.txt": "a\n"}},
+		{git: []string{"tag base", "checkout -q -b feature"}},
+		{write: map[string]string{"b.txt": "b\n"}},
+		{git: []string{"checkout -q main"}},
+		{write: map[string]string{"c.txt": "c\n"}},
+		{git: []string{"merge -q --no-edit feature"}},
+	})
+	native, git := repoPair(t, root)
+	merge, err := git.Resolve("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []GitRepo{native, git} {
+		commits, err := r.Log("base", "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for _, c := range commits {
+			if c.SHA == merge {
+				t.Errorf("%T: Log includes the merge commit", r)
+			}
+			files = append(files, c.Files...)
+		}
+		if want := []string{"c.txt", "b.txt"}; !reflect.DeepEqual(files, want) {
+			t.Errorf("%T: Log touches %q, want %q", r, files, want)
+		}
+	}
+}
+
+// ReadFile and Mode report a missing commit or path as ErrNotFound, each
+// in one git process for ExecRepo.
+func TestReadFileAndMode(t *testing.T) {
+	root := gitFixture(t, []fixtureStep{{
+		write:   map[string]string{"a.txt": "a\n", "dir/b.txt": "b\n"},
+		symlink: map[string]string{"link": "a.txt"},
+	}})
+	native, git := repoPair(t, root)
+	head, err := git.Resolve("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		commit, path string
+		data, mode   string // "" for ErrNotFound
+	}{
+		{"HEAD", "a.txt", "a\n", "100644"},
+		{head, "dir/b.txt", "b\n", "100644"},
+		{"HEAD", "link", "a.txt", "120000"},
+		{"HEAD", "dir", "", "40000"},
+		{"HEAD", "missing.txt", "", ""},
+		{"5555555555555555555555555555555555555555", "a.txt", "", ""},
+		{"no-such-branch", "a.txt", "", ""},
+	}
+	for _, r := range []GitRepo{native, git} {
+		for _, tt := range tests {
+			data, err := r.ReadFile(tt.commit, tt.path)
+			if tt.data == "" && !errors.Is(err, ErrNotFound) || tt.data != "" && (string(data) != tt.data || err != nil) {
+				t.Errorf("%T.ReadFile(%s, %s) = %q, %v; want %q", r, tt.commit, tt.path, data, err, tt.data)
+			}
+			mode, err := r.Mode(tt.commit, tt.path)
+			if tt.mode == "" && !errors.Is(err, ErrNotFound) || tt.mode != "" && (mode != tt.mode || err != nil) {
+				t.Errorf("%T.Mode(%s, %s) = %q, %v; want %q", r, tt.commit, tt.path, mode, err, tt.mode)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package keyguard
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// tokenPattern matches anything shaped like a submission key.
+var tokenPattern = regexp.MustCompile(`AIKEY-[A-Za-z2-7]{8,}`)
+
+// KeyStatus classifies a key token found in a submission.
+type KeyStatus string
+
+const (
+	// KeyCurrent is the key at the anchor, or one rotated out within the
+	// grace window.
+	KeyCurrent KeyStatus = "current"
+	// KeyStale is an earlier generation from the key history, rotated out
+	// before the grace window.
+	KeyStale KeyStatus = "stale"
+	// KeyForeign is not a key of this repository: copied from another
+	// repository, or from training data.
+	KeyForeign KeyStatus = "foreign"
+)
+
+// KeyToken is one distinct key token in a file.
+type KeyToken struct {
+	Key    string
+	Line   int // first line it appears on, 1-based
+	Status KeyStatus
+}
+
+// ClassifyKeyTokens returns every distinct key token in data, in order of
+// first appearance, classified against the accepted keys and the history.
+// Every token counts, wherever it sits in the file: a made-up key in a
+// test fixture or an example in the docs is exempted by IgnoreFile, not by
+// its position.
+func ClassifyKeyTokens(data []byte, accepted []string, history KeyHistory) []KeyToken {
+	var tokens []KeyToken
+	seen := map[string]bool{}
+	for _, loc := range tokenPattern.FindAllIndex(data, -1) {
+		key := string(data[loc[0]:loc[1]])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		line := bytes.Count(data[:loc[0]], []byte("\n")) + 1
+		tokens = append(tokens, classifyKey(key, line, accepted, history))
+	}
+	return tokens
+}
+
+// classifyKey classifies one token found on line.
+func classifyKey(key string, line int, accepted []string, history KeyHistory) KeyToken {
+	t := KeyToken{Key: key, Line: line, Status: KeyForeign}
+	for _, k := range accepted {
+		if k == key {
+			t.Status = KeyCurrent
+		}
+	}
+	if _, ok := history.Lookup(key); ok && t.Status != KeyCurrent {
+		t.Status = KeyStale
+	}
+	return t
+}
+
+// IsKeyFile reports whether path is one of the files that carry or record
+// the key itself (key.agents_.md, AGENTS.md, the key history), where any
+// generation may legitimately appear.
+func IsKeyFile(path string) bool {
+	if path == HistoryFile {
+		return true
+	}
+	for _, name := range candidateFiles {
+		if path == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package keyguard
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClassifyKeyTokens(t *testing.T) {
+	const (
+		current = "AIKEY-currentkey2345"
+		old     = "AIKEY-oldkey234567"
+		foreign = "AIKEY-foreignkey234"
+	)
+	history := KeyHistory{{Generation: 1, Key: old}, {Generation: 2, Key: current}}
+	tests := []struct {
+		name, data string
+		want       []KeyToken
+	}{
+		{
+			name: "line comments",
+			data: "// " + current + "\n// " + old + "\npackage main\n// " + current + "\n",
+			want: []KeyToken{{current, 1, KeyCurrent}, {old, 2, KeyStale}},
+		},
+		{
+			name: "block comment",
+			data: "# Title\n<!--\n  " + foreign + "\n-->\n",
+			want: []KeyToken{{foreign, 3, KeyForeign}},
+		},
+		{
+			// Where a token sits does not matter; fixtures are exempted
+			// through IgnoreFile.
+			name: "string literal",
+			data: "package main\n\nconst fixture = \"Key: " + foreign + "\"\n",
+			want: []KeyToken{{foreign, 3, KeyForeign}},
+		},
+		{
+			name: "after the header",
+			data: "import os\n\n\n# " + foreign + "\n",
+			want: []KeyToken{{foreign, 4, KeyForeign}},
+		},
+		{
+			name: "after code",
+			data: "echo hi # " + old + "\n",
+			want: []KeyToken{{old, 1, KeyStale}},
+		},
+		{
+			name: "json",
+			data: "{\"body\": \"" + foreign + "\"}\n",
+			want: []KeyToken{{foreign, 1, KeyForeign}},
+		},
+		{name: "too short", data: "AIKEY-abc123\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyKeyTokens([]byte(tt.data), []string{current}, history)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClassifyKeyTokens = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Every file in the repository outside the root IgnoreFile and the key
+// files carries the repository key or none at all: the made-up keys in the
+// test fixtures are exempted by IgnoreFile.
+func TestClassifyKeyTokensRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := filepath.Join("..", "..")
+	key, err := ReadKey(root)
+	if err != nil || key == "" {
+		t.Fatalf("ReadKey = %q, %v", key, err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, IgnoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignore := ParseIgnore(data)
+	for _, fixture := range []string{
+		"pkg/keyguard/tokens_test.go",
+		"pkg/keyguard/testdata/events/pull_request.json",
+		"pkg/keyguard/testdata/key.history",
+		"crates/inject-key/src/main.rs",
+	} {
+		if ok, _ := ignore.Match(fixture); !ok {
+			t.Errorf("%s does not exempt %s", IgnoreFile, fixture)
+		}
+	}
+
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		t.Skipf("git ls-files: %v", err)
+	}
+	files, _ := ignore.Filter(strings.Fields(string(out)))
+	for _, rel := range files {
+		if IsKeyFile(rel) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // deleted in the worktree
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tok := range ClassifyKeyTokens(data, []string{key}, nil) {
+			if tok.Status != KeyCurrent {
+				t.Errorf("%s:%d: %s key %s", rel, tok.Line, tok.Status, tok.Key)
+			}
+		}
+	}
+}
+
+func TestCheckPlacement(t *testing.T) {
+	const key = "AIKEY-currentkey2345"
+	tests := []struct {
+		name, path, data string
+		ok               bool
+		reason           string
+	}{
+		{"line comment", "main.go", "// " + key + "\npackage main\n", true, ""},
+		{"indented", "main.py", "\n    # AI-Key: " + key + "\n", true, ""},
+		{"block comment", "index.html", "<html>\n<!-- " + key + " -->\n", true, ""},
+		{"open block comment", "index.html", "<!--\n  " + key + "\n-->\n", true, ""},
+		{"after a closed block", "index.html", "<!-- x --> " + key + "\n", false, "key on line 1 is not in a comment"},
+		{"string literal", "main.go", "package main\nvar k = \"" + key + "\"\n", false, "key on line 2 is not in a comment"},
+		{"too far down", "main.go", "package main\n\n\n// " + key + "\n", false, "key first appears on line 4, past the first 3 lines"},
+		{"missing", "main.go", "package main\n", false, "key not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := CheckPlacement(tt.path, []byte(tt.data), key, 3)
+			if ok != tt.ok || reason != tt.reason {
+				t.Errorf("CheckPlacement = %v, %q; want %v, %q", ok, reason, tt.ok, tt.reason)
+			}
+		})
+	}
+}
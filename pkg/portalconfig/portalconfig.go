@@ -0,0 +1,75 @@
+// Package portalconfig reads a repository's .portal-config.yaml, the opt-in
+// marker and per-repo settings file for tooling deployed from this repo.
+//
+// Each tool owns one top-level section and decodes it into its own types
+// with Section, so this package does not need to know their shape.
+package portalconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config file's name at the repository root.
+const FileName = ".portal-config.yaml"
+
+// Config is a parsed .portal-config.yaml.  The zero value (and a nil
+// *Config) behaves as an empty file.
+type Config struct {
+	sections map[string]yaml.Node
+}
+
+// Load reads FileName from repoRoot's working tree.  A missing file yields
+// an empty Config, not an error.
+func Load(repoRoot string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot, FileName))
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("portalconfig: read %s: %w", FileName, err)
+	}
+	return Parse(data)
+}
+
+// Parse parses the content of a .portal-config.yaml file.
+func Parse(data []byte) (*Config, error) {
+	var sections map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("portalconfig: parse %s: %w", FileName, err)
+	}
+	return &Config{sections: sections}, nil
+}
+
+// Section decodes the top-level section name into out.  It reports false
+// (and leaves out untouched) when the section is absent.  Unknown keys
+// inside the section are an error, so typos don't silently fall back to
+// defaults.
+func (c *Config) Section(name string, out any) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	node, ok := c.sections[name]
+	if !ok {
+		return false, nil
+	}
+	if err := decodeStrict(&node, out); err != nil {
+		return true, fmt.Errorf("portalconfig: section %q: %w", name, err)
+	}
+	return true, nil
+}
+
+// decodeStrict decodes node into out, rejecting unknown fields.
+func decodeStrict(node *yaml.Node, out any) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(out)
+}
@@ -0,0 +1,144 @@
+// aiscan_bayes builds and inspects models for the naive-Bayes AI scanner
+// (AI_SCAN_BACKEND=bayes).
+//
+// Usage:
+//
+//	go run ./tools/aiscan_bayes train -human <dir> -ai <dir> [-manifest <file>] -o <model>
+//	go run ./tools/aiscan_bayes top [-n 25] <model>
+//
+// train counts word and bigram frequencies over a labeled corpus (the same
+// inputs aiscan_eval accepts) and writes a compact gzip model that can be
+// committed, pointed at with AI_SCAN_BAYES_MODEL, or embedded with
+// go:embed and loaded with aiscan.ParseBayesModel.  Without
+// AI_SCAN_BAYES_MODEL the backend uses the built-in model, which go
+// generate ./pkg/aiscan retrains from pkg/aiscan/testdata/bayes.
+//
+// top prints the features the model considers most indicative of each
+// class, as a sanity check after training.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/portal-co/scripts/pkg/aiscan"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "train":
+		train(os.Args[2:])
+	case "top":
+		top(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  aiscan_bayes train (-human <dir> -ai <dir> | -manifest <file>) -o <model> [-min-count 2]")
+	fmt.Fprintln(os.Stderr, "  aiscan_bayes top [-n 25] <model>")
+	os.Exit(2)
+}
+
+func train(args []string) {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	humanDir := fs.String("human", "", "directory of human-written files")
+	aiDir := fs.String("ai", "", "directory of AI-written files")
+	manifest := fs.String("manifest", "", "JSONL corpus manifest")
+	out := fs.String("o", "", "output model file")
+	minCount := fs.Int("min-count", 2, "drop features seen fewer times than this")
+	fs.Parse(args)
+
+	if *out == "" || (*humanDir == "" && *aiDir == "" && *manifest == "") {
+		usage()
+	}
+
+	samples, err := aiscan.LoadCorpusDirs(*humanDir, *aiDir)
+	if err != nil {
+		fatalf("error: %v\n", err)
+	}
+	if *manifest != "" {
+		more, err := aiscan.LoadCorpusManifest(*manifest)
+		if err != nil {
+			fatalf("error: %v\n", err)
+		}
+		samples = append(samples, more...)
+	}
+
+	model := aiscan.TrainBayes(samples, *minCount)
+	if model.Docs[0] == 0 || model.Docs[1] == 0 {
+		fatalf("error: corpus needs both human and AI samples (got %d human, %d AI)\n", model.Docs[0], model.Docs[1])
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fatalf("error: %v\n", err)
+	}
+	n, err := model.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fatalf("error writing %s: %v\n", *out, err)
+	}
+	fmt.Printf("Trained on %d human and %d AI document(s): %d feature(s), %d bytes → %s\n",
+		model.Docs[0], model.Docs[1], len(model.Counts), n, *out)
+}
+
+func top(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	n := fs.Int("n", 25, "features to show per class")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	model, err := aiscan.LoadBayesModel(fs.Arg(0))
+	if err != nil {
+		fatalf("error: %v\n", err)
+	}
+
+	type ranked struct {
+		feature string
+		count   [2]uint32
+		score   float64
+	}
+	var all []ranked
+	for f, c := range model.Counts {
+		// Smoothed class-frequency ratio, ignoring the (shared) vocabulary
+		// term; good enough for ranking.
+		h := (float64(c[0]) + 1) / float64(model.Docs[0]+1)
+		a := (float64(c[1]) + 1) / float64(model.Docs[1]+1)
+		all = append(all, ranked{f, c, a / h})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].feature < all[j].feature
+	})
+
+	fmt.Printf("Model: %d human / %d AI document(s), %d feature(s)\n\n", model.Docs[0], model.Docs[1], len(model.Counts))
+	fmt.Println("Most AI-indicative:")
+	for i := 0; i < *n && i < len(all); i++ {
+		r := all[i]
+		fmt.Printf("  %-32s human %-6d ai %d\n", r.feature, r.count[0], r.count[1])
+	}
+	fmt.Println("\nMost human-indicative:")
+	for i := 0; i < *n && i < len(all); i++ {
+		r := all[len(all)-1-i]
+		fmt.Printf("  %-32s human %-6d ai %d\n", r.feature, r.count[0], r.count[1])
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,328 @@
+// aiscan_eval measures an AI scanner against a labeled corpus.
+//
+// Usage:
+//
+//	go run ./tools/aiscan_eval -human <dir> -ai <dir> [-json <file>]
+//	go run ./tools/aiscan_eval -manifest <corpus.jsonl> [-json <file>]
+//
+// The scanner is built exactly as check_ai_key builds it (aiscan.FromEnv),
+// so AI_SCAN_BACKEND and friends select what is measured.  The report has
+// precision, recall, F1 and a confusion matrix for the scanner's own
+// verdicts, followed by ROC AUC and a threshold sweep over the reported
+// confidences.
+//
+// Flags:
+//
+//	-human <dir>          directory of human-written files
+//	-ai <dir>             directory of AI-written files
+//	-manifest <file>      JSONL manifest (see aiscan.LoadCorpusManifest);
+//	                      may be combined with -human/-ai
+//	-portal-config <file> .portal-config.yaml whose ai-scan section tunes
+//	                      the scanner (default: none)
+//	-steps <n>            threshold sweep steps (default 20)
+//	-json <file>          also write the report as JSON ("-" for stdout,
+//	                      which suppresses the text report)
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/portal-co/scripts/pkg/aiscan"
+	"github.com/portal-co/scripts/pkg/portalconfig"
+)
+
+func main() {
+	humanDir := flag.String("human", "", "directory of human-written files")
+	aiDir := flag.String("ai", "", "directory of AI-written files")
+	manifest := flag.String("manifest", "", "JSONL corpus manifest")
+	portalCfg := flag.String("portal-config", "", "path to a .portal-config.yaml with an ai-scan section")
+	steps := flag.Int("steps", 20, "threshold sweep steps")
+	jsonOut := flag.String("json", "", `write the report as JSON to this file ("-" for stdout)`)
+	flag.Parse()
+
+	if *humanDir == "" && *aiDir == "" && *manifest == "" {
+		fmt.Fprintln(os.Stderr, "Usage: aiscan_eval (-human <dir> -ai <dir> | -manifest <file>) [-json <file>]")
+		os.Exit(2)
+	}
+	if *steps < 1 {
+		fatalf("-steps must be at least 1\n")
+	}
+
+	samples, err := loadSamples(*humanDir, *aiDir, *manifest)
+	if err != nil {
+		fatalf("error: %v\n", err)
+	}
+	if len(samples) == 0 {
+		fatalf("error: corpus is empty\n")
+	}
+
+	rc, err := loadRepoConfig(*portalCfg)
+	if err != nil {
+		fatalf("error: %v\n", err)
+	}
+	scanner, err := aiscan.FromEnv(rc)
+	if err != nil {
+		fatalf("cannot build AI scanner: %v\n", err)
+	}
+	defer aiscan.Close(scanner)
+
+	files := make([]aiscan.File, len(samples))
+	for i, s := range samples {
+		files[i] = aiscan.File{Path: s.Path, Content: s.Content}
+	}
+	results := aiscan.ScanAll(scanner, files)
+
+	rep := evaluate(samples, results, *steps)
+	rep.Scanner = fmt.Sprintf("%T", scanner)
+
+	if *jsonOut != "-" {
+		rep.writeText(os.Stdout)
+	}
+	if *jsonOut != "" {
+		if err := writeJSON(*jsonOut, rep); err != nil {
+			fatalf("error writing JSON report: %v\n", err)
+		}
+	}
+}
+
+func loadSamples(humanDir, aiDir, manifest string) ([]aiscan.Sample, error) {
+	samples, err := aiscan.LoadCorpusDirs(humanDir, aiDir)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != "" {
+		more, err := aiscan.LoadCorpusManifest(manifest)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, more...)
+	}
+	return samples, nil
+}
+
+func loadRepoConfig(path string) (aiscan.RepoConfig, error) {
+	var rc aiscan.RepoConfig
+	if path == "" {
+		return rc, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rc, err
+	}
+	pc, err := portalconfig.Parse(data)
+	if err != nil {
+		return rc, err
+	}
+	_, err = pc.Section("ai-scan", &rc)
+	return rc, err
+}
+
+// ─── metrics ─────────────────────────────────────────────────────────────────
+
+// confusion is a binary confusion matrix with AI as the positive class.
+type confusion struct {
+	TP int `json:"tp"`
+	FP int `json:"fp"`
+	TN int `json:"tn"`
+	FN int `json:"fn"`
+}
+
+func (c *confusion) add(actualAI, predictedAI bool) {
+	switch {
+	case actualAI && predictedAI:
+		c.TP++
+	case actualAI:
+		c.FN++
+	case predictedAI:
+		c.FP++
+	default:
+		c.TN++
+	}
+}
+
+func (c confusion) precision() float64 { return ratio(c.TP, c.TP+c.FP) }
+func (c confusion) recall() float64    { return ratio(c.TP, c.TP+c.FN) }
+func (c confusion) fpr() float64       { return ratio(c.FP, c.FP+c.TN) }
+func (c confusion) accuracy() float64  { return ratio(c.TP+c.TN, c.TP+c.TN+c.FP+c.FN) }
+func (c confusion) f1() float64 {
+	p, r := c.precision(), c.recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// sweepRow is one threshold of the sweep / ROC table.
+type sweepRow struct {
+	Threshold float64 `json:"threshold"`
+	confusion
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	FPR       float64 `json:"fpr"`
+}
+
+// fileError records a sample the scanner could not score.
+type fileError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type report struct {
+	Scanner   string      `json:"scanner"`
+	Samples   int         `json:"samples"`
+	AI        int         `json:"ai"`
+	Human     int         `json:"human"`
+	Errors    []fileError `json:"errors,omitempty"`
+	Verdicts  confusion   `json:"verdicts"`
+	Precision float64     `json:"precision"`
+	Recall    float64     `json:"recall"`
+	F1        float64     `json:"f1"`
+	Accuracy  float64     `json:"accuracy"`
+	AUC       float64     `json:"roc_auc"`
+	Sweep     []sweepRow  `json:"sweep"`
+}
+
+// scored is a successfully scanned sample.
+type scored struct {
+	ai         bool
+	confidence float64
+}
+
+func evaluate(samples []aiscan.Sample, results []aiscan.BatchResult, steps int) report {
+	rep := report{Samples: len(samples)}
+	var points []scored
+	for i, s := range samples {
+		if s.AI {
+			rep.AI++
+		} else {
+			rep.Human++
+		}
+		r := results[i]
+		if r.Err != nil {
+			rep.Errors = append(rep.Errors, fileError{Path: s.Path, Error: r.Err.Error()})
+			continue
+		}
+		rep.Verdicts.add(s.AI, r.LikelyAI)
+		points = append(points, scored{ai: s.AI, confidence: r.Confidence})
+	}
+	rep.Precision = rep.Verdicts.precision()
+	rep.Recall = rep.Verdicts.recall()
+	rep.F1 = rep.Verdicts.f1()
+	rep.Accuracy = rep.Verdicts.accuracy()
+	rep.AUC = rocAUC(points)
+
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		var c confusion
+		for _, p := range points {
+			c.add(p.ai, p.confidence >= t)
+		}
+		rep.Sweep = append(rep.Sweep, sweepRow{
+			Threshold: t,
+			confusion: c,
+			Precision: c.precision(),
+			Recall:    c.recall(),
+			F1:        c.f1(),
+			FPR:       c.fpr(),
+		})
+	}
+	return rep
+}
+
+// rocAUC is the area under the ROC curve, computed exactly as the
+// probability that a random AI sample scores above a random human one
+// (ties count half).
+func rocAUC(points []scored) float64 {
+	sorted := append([]scored(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].confidence < sorted[j].confidence })
+
+	var pos, neg int
+	for _, p := range sorted {
+		if p.ai {
+			pos++
+		} else {
+			neg++
+		}
+	}
+	if pos == 0 || neg == 0 {
+		return 0
+	}
+
+	// Sum of ranks of the positives, with tied scores sharing their mean rank.
+	var rankSum float64
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].confidence == sorted[i].confidence {
+			j++
+		}
+		meanRank := float64(i+j+1) / 2 // ranks are 1-based: (i+1 + j) / 2
+		for k := i; k < j; k++ {
+			if sorted[k].ai {
+				rankSum += meanRank
+			}
+		}
+		i = j
+	}
+	u := rankSum - float64(pos*(pos+1))/2
+	return u / float64(pos*neg)
+}
+
+// ─── output ──────────────────────────────────────────────────────────────────
+
+func (rep report) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Scanner: %s\n", rep.Scanner)
+	fmt.Fprintf(w, "Corpus:  %d sample(s) — %d AI, %d human; %d scan error(s)\n\n", rep.Samples, rep.AI, rep.Human, len(rep.Errors))
+	for _, e := range rep.Errors {
+		fmt.Fprintf(w, "  error  %s: %s\n", e.Path, e.Error)
+	}
+	if len(rep.Errors) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	c := rep.Verdicts
+	fmt.Fprintln(w, "Confusion matrix (scanner verdicts):")
+	fmt.Fprintf(w, "                 %12s  %15s\n", "predicted AI", "predicted human")
+	fmt.Fprintf(w, "  actual AI      %12d  %15d\n", c.TP, c.FN)
+	fmt.Fprintf(w, "  actual human   %12d  %15d\n\n", c.FP, c.TN)
+
+	fmt.Fprintf(w, "Precision %.3f   Recall %.3f   F1 %.3f   Accuracy %.3f\n", rep.Precision, rep.Recall, rep.F1, rep.Accuracy)
+	fmt.Fprintf(w, "ROC AUC   %.3f\n\n", rep.AUC)
+
+	fmt.Fprintln(w, "Threshold sweep (confidence >= threshold counts as AI):")
+	fmt.Fprintf(w, "  %9s  %4s %4s %4s %4s  %9s  %6s  %5s  %5s\n", "threshold", "TP", "FP", "TN", "FN", "precision", "recall", "F1", "FPR")
+	for _, r := range rep.Sweep {
+		fmt.Fprintf(w, "  %9.2f  %4d %4d %4d %4d  %9.3f  %6.3f  %5.3f  %5.3f\n",
+			r.Threshold, r.TP, r.FP, r.TN, r.FN, r.Precision, r.Recall, r.F1, r.FPR)
+	}
+}
+
+func writeJSON(path string, rep report) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	os.Exit(1)
+}
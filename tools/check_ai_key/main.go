@@ -1,8 +1,36 @@
 // check_ai_key is the CI entrypoint for the AI-submission key check.
 //
-// It resolves the correct anchor commit for the current CI event, reads the
+// It resolves the correct anchor commit for the current CI event (GitHub
+// Actions, Forgejo/Gitea Actions, GitLab CI, or a local run), reads the
 // expected key from that commit, and then for every changed file verifies
-// either (a) the key is present or (b) an AI scanner does not flag the file.
+// either (a) the key is present, in the file or in the message of a commit
+// touching it, or (b) an AI scanner does not flag the file.  A key in the
+// pull/merge request description (from the CI event payload, or GitLab's
+// CI_MERGE_REQUEST_DESCRIPTION) covers every file.
+//
+// Paths matching the .aikeyignore file at the anchor commit (gitignore
+// syntax) are not checked at all.
+//
+// Any other AIKEY- token in a changed file, wherever it appears, fails the
+// check outright: it is either a stale key (an earlier generation in
+// key.history) or a foreign one.  Test fixtures and docs with made-up keys
+// belong in .aikeyignore.
+//
+// A key in a file counts only where the file's comment syntax puts it near
+// the top (AI_KEY_PLACEMENT=error); by default a misplaced key is counted
+// with a warning.
+//
+// File content is read from the HEAD commit's tree in CI and from the
+// working tree locally (AI_KEY_CONTENT=tree|worktree overrides; the diff
+// scan scope always reads the tree); symbolic links and Git LFS pointers
+// are skipped.
+//
+// Keys rotated out less than AI_KEY_GRACE ago, according to the key.history
+// ledger at the anchor commit, are accepted as well; every keyed file is
+// logged with the generation of the key it carries.
+//
+// With -list-backends it instead prints the registered scanner backends and
+// the environment variables each one reads, and exits.
 //
 // Exit codes:
 //
@@ -12,19 +40,44 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/portal-co/scripts/pkg/aiscan"
 	"github.com/portal-co/scripts/pkg/keyguard"
+	"github.com/portal-co/scripts/pkg/portalconfig"
 	"github.com/portal-co/scripts/pkg/repoutils"
 )
 
 func main() {
+	listBackends := flag.Bool("list-backends", false, "list the registered AI scanner backends and their config variables")
+	flag.Parse()
+	if *listBackends {
+		printBackends()
+		return
+	}
 	os.Exit(run())
 }
 
+// printBackends writes every registered backend with its config keys.
+func printBackends() {
+	for _, b := range aiscan.Backends() {
+		fmt.Println(b.Name)
+		for _, k := range b.Keys {
+			line := fmt.Sprintf("    %-24s %-9s %s", k.Env, k.Type, k.Help)
+			if k.Default != "" {
+				line += fmt.Sprintf(" (default %s)", k.Default)
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+}
+
 func run() int {
 	// ── 1. Resolve repo root ─────────────────────────────────────────────────
 	repoRoot, err := repoutils.GetRepoRoot()
@@ -32,17 +85,21 @@ func run() int {
 		errorf("cannot determine repo root: %v\n", err)
 		return 2
 	}
-
-	// ── 2. Build the Scanner from environment ────────────────────────────────
-	scanner, err := aiscan.FromEnv()
+	repo, err := keyguard.OpenRepo(repoRoot)
 	if err != nil {
-		errorf("cannot build AI scanner: %v\n", err)
+		errorf("cannot open git repository: %v\n", err)
 		return 2
 	}
-	logf("AI scanner: %T\n", scanner)
+	defer keyguard.Close(repo)
 
-	// ── 3. Resolve the anchor commit ─────────────────────────────────────────
-	anchor, err := keyguard.BaseCommit(repoRoot)
+	// ── 2. Resolve the anchor commit ─────────────────────────────────────────
+	ci := keyguard.DetectCI(os.Getenv)
+	if ci.Event() == keyguard.EventPullRequest {
+		logf("CI: %s, pull request #%d into %s\n", ci.Provider(), ci.PRNumber(), ci.BaseRef())
+	} else {
+		logf("CI: %s, %s\n", ci.Provider(), ci.Event())
+	}
+	anchor, err := keyguard.BaseCommit(repo, ci)
 	if err != nil {
 		errorf("cannot resolve base commit: %v\n", err)
 		return 2
@@ -53,8 +110,8 @@ func run() int {
 	}
 	logf("Anchor commit: %s\n", anchor)
 
-	// ── 4. Read the expected key at the anchor commit ────────────────────────
-	key, err := keyguard.ReadKeyAtCommit(repoRoot, anchor)
+	// ── 3. Read the expected key at the anchor commit ────────────────────────
+	key, err := keyguard.ReadKeyAtCommit(repo, anchor)
 	if err != nil {
 		errorf("cannot read key at anchor commit: %v\n", err)
 		return 2
@@ -64,25 +121,183 @@ func run() int {
 		return 0
 	}
 	logf("Expected key: %s\n", key)
+	keys, err := acceptedKeys(repo, anchor, key)
+	if err != nil {
+		errorf("%v\n", err)
+		return 2
+	}
 
-	// ── 5. Determine changed files ───────────────────────────────────────────
-	files, err := keyguard.ChangedFiles(repoRoot, anchor)
+	// ── 4. Determine changed files ───────────────────────────────────────────
+	files, err := keyguard.ChangedFiles(repo, anchor)
 	if err != nil {
 		errorf("cannot determine changed files: %v\n", err)
 		return 2
 	}
+	// .aikeyignore is read at the anchor, like the key itself.
+	ignore, err := keyguard.ReadIgnoreAtCommit(repo, anchor)
+	if err != nil {
+		errorf("cannot read %s at anchor commit: %v\n", keyguard.IgnoreFile, err)
+		return 2
+	}
+	changed := files
+	files, ignored := ignore.Filter(changed)
+	for _, rel := range changed {
+		if r, ok := ignored[rel]; ok {
+			logf("  skip  %s (%s:%d %q)\n", rel, keyguard.IgnoreFile, r.Line, r.Pattern)
+		}
+	}
+	// In CI the content is read from the HEAD commit, so what is checked is
+	// exactly what was submitted.
+	head, err := repo.Resolve("HEAD")
+	if err != nil {
+		errorf("cannot resolve HEAD: %v\n", err)
+		return 2
+	}
+	src, mode, err := keyguard.ContentFromEnv(repoRoot, repo, head, ci)
+	if err != nil {
+		errorf("%v\n", err)
+		return 2
+	}
+	scope, err := scanScopeFromEnv()
+	if err != nil {
+		errorf("%v\n", err)
+		return 2
+	}
+	// The diff scope's hunks are those of HEAD: applied to the working
+	// tree, uncommitted edits would shift them onto the wrong lines.
+	if scope.diff && mode == "worktree" {
+		if os.Getenv("AI_KEY_CONTENT") != "" {
+			errorf("AI_SCAN_SCOPE=diff scans the lines HEAD adds and needs AI_KEY_CONTENT=tree\n")
+			return 2
+		}
+		src, mode = keyguard.TreeContent{Repo: repo, Commit: head}, "tree"
+	}
+	// Each changed file is read for the key, its placement, stray key
+	// tokens and the AI scan; read it once.
+	src = &keyguard.CachedContent{Src: src}
+	where := "working tree"
+	if mode == "tree" {
+		where = "HEAD commit"
+	}
+	logf("Reading content from the %s (AI_KEY_CONTENT=%s)\n", where, mode)
+	files, err = submittedFiles(src, files)
+	if err != nil {
+		errorf("cannot read changed files: %v\n", err)
+		return 2
+	}
 	if len(files) == 0 {
 		logf("No changed files to check.\n")
 		return 0
 	}
 	logf("Checking %d changed file(s)...\n", len(files))
 
-	// ── 6. Find files missing the key ────────────────────────────────────────
-	missing, err := keyguard.ScanForKey(repoRoot, files, key)
+	// ── 5. Reject stale and foreign keys ─────────────────────────────────────
+	placement, maxLines, err := keyguard.PlacementFromEnv()
+	if err != nil {
+		errorf("%v\n", err)
+		return 2
+	}
+	// Any other key token is a stale key from an earlier session or a key
+	// from somewhere else entirely: exactly the pre-loaded or training-data
+	// key rotation exists to catch.  Fixtures and docs that carry made-up
+	// keys belong in .aikeyignore.  This runs before the pull request
+	// description can cover the submission.
+	bad, err := badKeyTokens(src, files, keys)
+	if err != nil {
+		errorf("error scanning files for key tokens: %v\n", err)
+		return 2
+	}
+	if len(bad) > 0 {
+		reportBadKeys(bad, keys)
+		return 1
+	}
+
+	// A key in the pull request description covers the whole submission.
+	pr, err := ci.PullRequest()
+	if err != nil {
+		errorf("cannot read pull request from CI: %v\n", err)
+		return 2
+	}
+	if pr != nil {
+		logf("Pull request #%d by %s: %s\n", pr.Number, pr.Author, pr.Title)
+		for _, k := range keys.keys {
+			if pr.HasKey(k) {
+				logf("Pull request description contains the submission key (%s); all changes are covered. ✓\n", keys.label(k))
+				return 0
+			}
+		}
+	}
+
+	// ── 6. Build the Scanner from environment and repo config ────────────────
+	// The repo config is read at the anchor so a submission cannot loosen
+	// the scanner that judges it.
+	repoCfg, err := loadRepoConfig(repo, anchor)
+	if err != nil {
+		errorf("cannot read %s at anchor commit: %v\n", portalconfig.FileName, err)
+		return 2
+	}
+	repoCfg.LoadMarkdown = func() (*aiscan.MarkdownProfile, error) {
+		return markdownBaseline(repo, anchor, repoCfg.PathPolicy())
+	}
+	scanner, err := aiscan.FromEnv(repoCfg)
+	if err != nil {
+		errorf("cannot build AI scanner: %v\n", err)
+		return 2
+	}
+	defer aiscan.Close(scanner)
+	logScanner("AI scanner", scanner)
+	cached := []aiscan.Scanner{scanner}
+	policy := repoCfg.PathPolicy()
+	scanner, err = relativeFromEnv(repoRoot, repo, anchor, scanner, policy)
+	if err != nil {
+		errorf("cannot build repository baseline: %v\n", err)
+		return 2
+	}
+	scanners, err := policyScanners(policy, repoCfg)
+	if err != nil {
+		errorf("cannot build AI scanner for %s policy: %v\n", portalconfig.FileName, err)
+		return 2
+	}
+	for _, s := range scanners {
+		defer aiscan.Close(s)
+		cached = append(cached, s)
+	}
+	scanners[""] = scanner
+
+	// ── 7. Find files missing the key ────────────────────────────────────────
+	found, missing, err := keyguard.ScanForKeys(src, files, keys.keys)
 	if err != nil {
 		errorf("error scanning files for key: %v\n", err)
 		return 2
 	}
+	for _, rel := range files {
+		k, ok := found[rel]
+		if !ok {
+			continue
+		}
+		if placement != keyguard.PlacementOff {
+			if ok, reason := checkPlacement(src, rel, k, maxLines); !ok {
+				if placement == keyguard.PlacementError {
+					logf("  error %s: %s; key not counted\n", rel, reason)
+					missing = append(missing, rel)
+					continue
+				}
+				logf("  warn  %s: %s\n", rel, reason)
+			}
+		}
+		logf("  key   %s (%s)\n", rel, keys.label(k))
+	}
+
+	// A commit whose message carries the key (e.g. as an AI-Key trailer)
+	// keys every file it touches.
+	if len(missing) > 0 {
+		commits, err := keyguard.SubmissionCommits(repo, anchor)
+		if err != nil {
+			errorf("cannot read submission commits: %v\n", err)
+			return 2
+		}
+		missing = keyedByCommits(missing, commits, keys)
+	}
 
 	if len(missing) == 0 {
 		logf("All changed files contain the submission key. ✓\n")
@@ -91,66 +306,532 @@ func run() int {
 
 	logf("%d file(s) do not contain the key; running AI scan...\n", len(missing))
 
-	// ── 7. AI-scan files that lack the key ───────────────────────────────────
-	failures := runAIScan(repoRoot, missing, key, scanner)
+	// ── 8. AI-scan files that lack the key ───────────────────────────────────
+	if scope.diff {
+		scope.hunks, err = keyguard.AddedHunks(repo, anchor)
+		if err != nil {
+			errorf("cannot determine added lines: %v\n", err)
+			return 2
+		}
+		logf("AI scan scope: added lines only (minimum %d)\n", scope.minAdded)
+	}
+	failures := runAIScan(repoRoot, src, missing, key, policy, scanners, scope)
+	logCacheStats(cached)
 
 	if len(failures) == 0 {
 		logf("AI scan found no AI-generated content in files missing the key. ✓\n")
 		return 0
 	}
 
-	// ── 8. Report failures ───────────────────────────────────────────────────
+	// ── 9. Report failures ───────────────────────────────────────────────────
 	fmt.Fprintf(os.Stderr, "\n❌  AI key check failed: %d file(s) appear AI-generated and are missing the submission key.\n\n", len(failures))
 	fmt.Fprintf(os.Stderr, "Expected key: %s\n\n", key)
 	fmt.Fprintf(os.Stderr, "To fix: embed the key (from key.agents_.md) in each flagged file.\n\n")
 	fmt.Fprintf(os.Stderr, "Flagged files:\n")
 	for _, f := range failures {
-		fmt.Fprintf(os.Stderr, "  %s (confidence %.0f%%)\n", f.path, f.confidence*100)
+		fmt.Fprintf(os.Stderr, "  %s (confidence %.0f%%)\n", f.path, f.result.Confidence*100)
+		printEvidence(f.path, f.result)
 	}
 	fmt.Fprintln(os.Stderr)
 	return 1
 }
 
+// keyedByCommits returns the paths in missing that no key-carrying commit
+// touches, logging the ones it accepts.  Newer keys are tried first.
+func keyedByCommits(missing []string, commits []keyguard.Commit, keys keySet) []string {
+	for _, key := range keys.keys {
+		keyed := keyguard.KeyedByCommits(commits, key)
+		var still []string
+		for _, rel := range missing {
+			c, ok := keyed[rel]
+			if !ok {
+				still = append(still, rel)
+				continue
+			}
+			logf("  key   %s (commit %.12s, %s, %s)\n", rel, c.SHA, keyguard.CommitKey(c, key), keys.label(key))
+		}
+		missing = still
+	}
+	return missing
+}
+
+// submittedFiles returns the paths in files that src holds as regular
+// files.  Symbolic links and Git LFS pointers carry no reviewable content
+// and are logged and left out, as are files src does not have.
+func submittedFiles(src keyguard.Content, files []string) ([]string, error) {
+	var out []string
+	for _, rel := range files {
+		_, kind, err := src.ReadFile(rel)
+		if errors.Is(err, keyguard.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind != keyguard.FileRegular {
+			logf("  skip  %s (%s)\n", rel, kind)
+			continue
+		}
+		out = append(out, rel)
+	}
+	return out, nil
+}
+
+// badKeyToken is a stale or foreign key token in a changed file.
+type badKeyToken struct {
+	path string
+	keyguard.KeyToken
+}
+
+// badKeyTokens returns the stale and foreign key tokens in files.  The key
+// files themselves are exempt: a rotation legitimately changes them.
+func badKeyTokens(src keyguard.Content, files []string, keys keySet) ([]badKeyToken, error) {
+	var bad []badKeyToken
+	for _, rel := range files {
+		if keyguard.IsKeyFile(rel) {
+			continue
+		}
+		data, _, err := src.ReadFile(rel)
+		if errors.Is(err, keyguard.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range keyguard.ClassifyKeyTokens(data, keys.keys, keys.history) {
+			if t.Status != keyguard.KeyCurrent {
+				bad = append(bad, badKeyToken{rel, t})
+			}
+		}
+	}
+	return bad, nil
+}
+
+// reportBadKeys explains a stale/foreign key failure on stderr.
+func reportBadKeys(bad []badKeyToken, keys keySet) {
+	fmt.Fprintf(os.Stderr, "\n❌  AI key check failed: %d stale or foreign submission key(s) found.\n\n", len(bad))
+	fmt.Fprintf(os.Stderr, "Expected key: %s\n\n", keys.current)
+	for _, b := range bad {
+		why := "not a key of this repository"
+		if b.Status == keyguard.KeyStale {
+			why = keys.label(b.Key)
+		}
+		fmt.Fprintf(os.Stderr, "  %s:%d  %s (%s: %s)\n", b.path, b.Line, b.Key, b.Status, why)
+	}
+	fmt.Fprintf(os.Stderr, "\nA stale key is from an earlier task session; a foreign key was copied from\n")
+	fmt.Fprintf(os.Stderr, "elsewhere or recalled from training data.  Either way the submission was not\n")
+	fmt.Fprintf(os.Stderr, "made with the current key.agents_.md.  Replace them with the expected key.\n\n")
+}
+
+// checkPlacement reports whether key sits in a comment near the top of
+// rel, as keyguard.CheckPlacement.
+func checkPlacement(src keyguard.Content, rel, key string, maxLines int) (bool, string) {
+	data, _, err := src.ReadFile(rel)
+	if err != nil {
+		return false, err.Error()
+	}
+	return keyguard.CheckPlacement(rel, data, key, maxLines)
+}
+
+// keySet is the keys a submission may carry: the key current at the anchor
+// and the ones rotated out within the grace window.
+type keySet struct {
+	current string
+	keys    []string // current first, then newest to oldest
+	history keyguard.KeyHistory
+}
+
+// acceptedKeys reads the key history at the anchor and AI_KEY_GRACE.
+func acceptedKeys(repo keyguard.GitRepo, anchor, current string) (keySet, error) {
+	history, err := keyguard.ReadKeyHistoryAtCommit(repo, anchor)
+	if err != nil {
+		return keySet{}, fmt.Errorf("cannot read %s at anchor commit: %w", keyguard.HistoryFile, err)
+	}
+	grace, err := keyguard.GraceFromEnv()
+	if err != nil {
+		return keySet{}, err
+	}
+	ks := keySet{current: current, history: history, keys: history.AcceptedKeys(current, time.Now(), grace)}
+	if n := len(ks.keys) - 1; n > 0 {
+		logf("Also accepting %d key(s) rotated out within the last %s (AI_KEY_GRACE)\n", n, grace)
+	}
+	return ks, nil
+}
+
+// label describes which generation key is, e.g. "generation 4, current"
+// or "generation 3, rotated out 2024-05-01 12:00 UTC".
+func (ks keySet) label(key string) string {
+	g, ok := ks.history.Lookup(key)
+	switch {
+	case key == ks.current && ok:
+		return fmt.Sprintf("generation %d, current", g.Generation)
+	case key == ks.current:
+		return "current key"
+	case !ok:
+		return "not in " + keyguard.HistoryFile
+	}
+	until, ok := ks.history.Superseded(key)
+	if !ok {
+		return fmt.Sprintf("generation %d, rotated out at an unknown time", g.Generation)
+	}
+	return fmt.Sprintf("generation %d, rotated out %s", g.Generation, until.UTC().Format("2006-01-02 15:04 UTC"))
+}
+
+// loadRepoConfig reads the "ai-scan" section of .portal-config.yaml as of
+// the anchor commit.  A missing file or section yields the zero config.
+func loadRepoConfig(repo keyguard.GitRepo, anchor string) (aiscan.RepoConfig, error) {
+	var rc aiscan.RepoConfig
+	data, err := keyguard.ReadFileAtCommit(repo, anchor, portalconfig.FileName)
+	if err != nil || data == nil {
+		return rc, err
+	}
+	pc, err := portalconfig.Parse(data)
+	if err != nil {
+		return rc, err
+	}
+	if _, err := pc.Section("ai-scan", &rc); err != nil {
+		return rc, err
+	}
+	return rc, rc.Policy.Validate()
+}
+
+// maxBaselineDocs caps the Markdown files read to build the baseline, so
+// huge doc trees don't cost one git process per file.
+const maxBaselineDocs = 200
+
+// markdownBaseline profiles the Markdown files in the anchor commit's tree
+// (other than those the policy skips) so the markdown-structure signal
+// judges a new doc by the repo's own conventions.  Heuristic scanners call
+// it through RepoConfig.LoadMarkdown, only once they score a Markdown file.
+func markdownBaseline(repo keyguard.GitRepo, anchor string, policy aiscan.Policy) (*aiscan.MarkdownProfile, error) {
+	paths, err := keyguard.ListFilesAtCommit(repo, anchor)
+	if err != nil {
+		return nil, err
+	}
+	var docs [][]byte
+	for _, p := range paths {
+		if len(docs) == maxBaselineDocs {
+			break
+		}
+		if aiscan.DetectLanguage(p, nil) != aiscan.LangMarkdown {
+			continue
+		}
+		if rule, _ := policy.Lookup(p); rule.Skip {
+			continue
+		}
+		data, err := keyguard.ReadFileAtCommit(repo, anchor, p)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, data)
+	}
+	profile := aiscan.BuildMarkdownProfile(docs)
+	logf("Markdown baseline: %d doc(s) at anchor\n", profile.Docs)
+	return &profile, nil
+}
+
+// maxProfileFiles caps the anchor files scanned to build the repository
+// baseline.
+const maxProfileFiles = 300
+
+// relativeFromEnv wraps inner in an aiscan.RelativeScanner when
+//
+//	AI_SCAN_RELATIVE    true → judge files against the repo's baseline
+//	AI_SCAN_RELATIVE_Z  z-score at which a file is flagged (default 2)
+//	AI_SCAN_PROFILE     JSON file caching the baseline; reused while the
+//	                    anchor commit and scanner are unchanged
+//
+// The baseline profiles the anchor commit's files that the policy scans
+// with the default backend, sampled evenly across the tree when there are
+// more than maxProfileFiles.
+func relativeFromEnv(repoRoot string, repo keyguard.GitRepo, anchor string, inner aiscan.Scanner, policy aiscan.Policy) (aiscan.Scanner, error) {
+	if v := strings.TrimSpace(os.Getenv("AI_SCAN_RELATIVE")); v == "" {
+		return inner, nil
+	} else if on, err := strconv.ParseBool(v); err != nil {
+		return nil, fmt.Errorf("invalid AI_SCAN_RELATIVE %q", v)
+	} else if !on {
+		return inner, nil
+	}
+	rs := &aiscan.RelativeScanner{Inner: inner}
+	if v := strings.TrimSpace(os.Getenv("AI_SCAN_RELATIVE_Z")); v != "" {
+		z, err := strconv.ParseFloat(v, 64)
+		if err != nil || z <= 0 {
+			return nil, fmt.Errorf("invalid AI_SCAN_RELATIVE_Z %q", v)
+		}
+		rs.Threshold = z
+	}
+
+	cachePath := strings.TrimSpace(os.Getenv("AI_SCAN_PROFILE"))
+	if cachePath != "" {
+		if p, err := aiscan.LoadProfile(cachePath); err == nil && p.Commit == anchor && p.Fingerprint == aiscan.Fingerprint(inner) {
+			logf("Repository baseline: reusing %s\n", cachePath)
+			rs.Profile = p
+			return rs, nil
+		}
+	}
+
+	paths, err := keyguard.ListFilesAtCommit(repo, anchor)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []string
+	for _, rel := range paths {
+		if rule, _ := policy.Lookup(rel); !rule.Skip && rule.Backend == "" {
+			candidates = append(candidates, rel)
+		}
+	}
+	var files []aiscan.File
+	for _, rel := range sampleEvenly(candidates, maxProfileFiles) {
+		content, err := keyguard.ReadFileAtCommit(repo, anchor, rel)
+		if err != nil {
+			return nil, err
+		}
+		if shouldSkip(content) {
+			continue
+		}
+		files = append(files, aiscan.File{Path: repoRoot + "/" + rel, Content: content})
+	}
+	rs.Profile = aiscan.BuildProfile(inner, files)
+	rs.Profile.Commit = anchor
+	logf("Repository baseline: profiled %d file(s) at anchor\n", len(files))
+	if cachePath != "" {
+		if err := rs.Profile.WriteFile(cachePath); err != nil {
+			logf("warning: could not save baseline: %v\n", err)
+		}
+	}
+	return rs, nil
+}
+
+// sampleEvenly returns n of paths spread evenly over the list, or all of
+// them when there are no more than n.  ListFiles sorts paths, so the
+// sample covers every part of the tree rather than its first directories.
+func sampleEvenly(paths []string, n int) []string {
+	if len(paths) <= n {
+		return paths
+	}
+	out := make([]string, n)
+	for i := range out {
+		out[i] = paths[i*len(paths)/n]
+	}
+	return out
+}
+
+// policyScanners builds one Scanner for each backend named by a policy
+// rule, keyed by backend name.
+func policyScanners(policy aiscan.Policy, rc aiscan.RepoConfig) (map[string]aiscan.Scanner, error) {
+	scanners := map[string]aiscan.Scanner{}
+	for i, r := range policy {
+		if r.Backend == "" || scanners[r.Backend] != nil {
+			continue
+		}
+		s, err := aiscan.BackendFromEnv(r.Backend, rc)
+		if err != nil {
+			for _, built := range scanners {
+				aiscan.Close(built)
+			}
+			return nil, fmt.Errorf("ai-scan.policy rule %d backend: %w", i+1, err)
+		}
+		logScanner(fmt.Sprintf("AI scanner for %q rules", r.Backend), s)
+		scanners[r.Backend] = s
+	}
+	return scanners, nil
+}
+
+// logCacheStats logs the hits and misses of every CachingScanner among
+// scanners, added up: they all share AI_SCAN_CACHE_DIR.
+func logCacheStats(scanners []aiscan.Scanner) {
+	var (
+		dir          string
+		hits, misses int64
+	)
+	for _, s := range scanners {
+		if cs, ok := s.(*aiscan.CachingScanner); ok {
+			h, m := cs.Stats()
+			dir, hits, misses = cs.Dir, hits+h, misses+m
+		}
+	}
+	if dir != "" {
+		logf("AI scan cache (%s): %d hit(s), %d miss(es)\n", dir, hits, misses)
+	}
+}
+
+// logScanner logs the concrete type behind s, looking through a cache.
+func logScanner(label string, s aiscan.Scanner) {
+	if cs, ok := s.(*aiscan.CachingScanner); ok {
+		logf("%s: %T (cached)\n", label, cs.Inner)
+	} else {
+		logf("%s: %T\n", label, s)
+	}
+}
+
 type flaggedFile struct {
-	path       string
-	confidence float64
+	path   string
+	result aiscan.Result
 }
 
-// runAIScan scans each path (relative to repoRoot) with scanner.
+// maxMatchesShown caps the matched lines printed per signal so a single
+// noisy file cannot flood the CI log.
+const maxMatchesShown = 10
+
+// printEvidence writes the signals that fired for a flagged file, with their
+// weighted contributions and the lines that matched.
+func printEvidence(path string, r aiscan.Result) {
+	for _, sig := range r.Signals {
+		if !sig.Fired() {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "      %-28s %.2f × %.2f → %.3f\n", sig.Name, sig.Value, sig.Weight, sig.Contribution)
+		for i, m := range sig.Matches {
+			if i == maxMatchesShown {
+				fmt.Fprintf(os.Stderr, "        … %d more\n", len(sig.Matches)-maxMatchesShown)
+				break
+			}
+			fmt.Fprintf(os.Stderr, "        %s:%d: %q\n", path, m.Line, m.Phrase)
+		}
+	}
+}
+
+// scanScope selects what runAIScan hands to the scanner: whole files, or
+// (diff) only the lines HEAD adds relative to the anchor.
+type scanScope struct {
+	diff     bool
+	minAdded int                        // non-blank added lines below which a file is skipped
+	hunks    map[string][]keyguard.Hunk // by repo-relative path; diff scope only
+}
+
+// scanScopeFromEnv reads
+//
+//	AI_SCAN_SCOPE            file (default) | diff
+//	AI_SCAN_MIN_ADDED_LINES  diff scope: skip files adding fewer non-blank
+//	                         lines than this (default 3)
+func scanScopeFromEnv() (scanScope, error) {
+	scope := scanScope{minAdded: 3}
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("AI_SCAN_SCOPE"))); v {
+	case "", "file":
+	case "diff":
+		scope.diff = true
+	default:
+		return scope, fmt.Errorf("unknown AI_SCAN_SCOPE %q (valid: file, diff)", v)
+	}
+	if v := strings.TrimSpace(os.Getenv("AI_SCAN_MIN_ADDED_LINES")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return scope, fmt.Errorf("invalid AI_SCAN_MIN_ADDED_LINES %q", v)
+		}
+		scope.minAdded = n
+	}
+	return scope, nil
+}
+
+// addedOnly blanks every line of content outside hunks, keeping the line
+// breaks so line numbers in the scanner's evidence still match the file.
+// It also returns the number of non-blank lines kept.
+func addedOnly(content []byte, hunks []keyguard.Hunk) ([]byte, int) {
+	lines := strings.SplitAfter(string(content), "\n")
+	keep := make([]bool, len(lines))
+	for _, h := range hunks {
+		for n := h.Start; n < h.Start+h.Count && n-1 < len(lines); n++ {
+			keep[n-1] = true
+		}
+	}
+	var (
+		b     strings.Builder
+		added int
+	)
+	for i, line := range lines {
+		if keep[i] {
+			b.WriteString(line)
+			if strings.TrimSpace(line) != "" {
+				added++
+			}
+		} else if strings.HasSuffix(line, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return []byte(b.String()), added
+}
+
+// runAIScan scans each path (relative to repoRoot, read from src) with the
+// scanner its policy rule selects (scanners[""] by default).
 // Files that are not flagged as AI-generated are silently passed.
+// In diff scope only the added lines of each file are scanned, and files
+// adding fewer than scope.minAdded non-blank lines are skipped.
 // The key parameter is unused here (key-presence was already checked) but
 // is kept in the signature for future use (e.g. checking key variants).
-func runAIScan(repoRoot string, paths []string, _ string, scanner aiscan.Scanner) []flaggedFile {
-	var failures []flaggedFile
-
+//
+// Readable, scannable files are collected per backend first and handed to
+// each scanner in one aiscan.ScanAll call, so backends that batch make a
+// single round trip; others are called per file.
+func runAIScan(repoRoot string, src keyguard.Content, paths []string, _ string, policy aiscan.Policy, scanners map[string]aiscan.Scanner, scope scanScope) []flaggedFile {
+	type job struct {
+		rel       string
+		threshold float64
+	}
+	var (
+		backends []string // in first-use order, for a stable log
+		files    = map[string][]aiscan.File{}
+		jobs     = map[string][]job{}
+	)
 	for _, rel := range paths {
+		rule, glob := policy.Lookup(rel)
+		if rule.Skip {
+			logf("  skip  %s (policy %q)\n", rel, glob)
+			continue
+		}
+
 		fullPath := repoRoot + "/" + rel
 
-		content, err := os.ReadFile(fullPath)
+		content, _, err := src.ReadFile(rel)
 		if err != nil {
-			if os.IsNotExist(err) {
+			if errors.Is(err, keyguard.ErrNotFound) {
 				continue // deleted file, not a submission
 			}
 			logf("warning: could not read %s: %v\n", rel, err)
 			continue
 		}
 
-		if shouldSkip(rel, content) {
+		if shouldSkip(content) {
 			logf("  skip  %s (binary or non-text)\n", rel)
 			continue
 		}
 
-		likelyAI, confidence, err := scanner.Scan(fullPath, content)
-		if err != nil {
-			logf("  warn  %s: scanner error: %v\n", rel, err)
-			continue
+		if scope.diff {
+			var added int
+			content, added = addedOnly(content, scope.hunks[rel])
+			if added < scope.minAdded {
+				logf("  skip  %s (%d added line(s))\n", rel, added)
+				continue
+			}
 		}
 
-		if likelyAI {
-			logf("  FAIL  %s (AI confidence %.0f%%)\n", rel, confidence*100)
-			failures = append(failures, flaggedFile{path: rel, confidence: confidence})
-		} else {
-			logf("  pass  %s (AI confidence %.0f%%)\n", rel, confidence*100)
+		if _, seen := jobs[rule.Backend]; !seen {
+			backends = append(backends, rule.Backend)
+		}
+		files[rule.Backend] = append(files[rule.Backend], aiscan.File{Path: fullPath, Content: content})
+		jobs[rule.Backend] = append(jobs[rule.Backend], job{rel: rel, threshold: rule.Threshold})
+	}
+
+	var failures []flaggedFile
+	for _, b := range backends {
+		for i, r := range aiscan.ScanAll(scanners[b], files[b]) {
+			j := jobs[b][i]
+			if r.Err != nil {
+				logf("  warn  %s: scanner error: %v\n", j.rel, r.Err)
+				continue
+			}
+			// Under AI_SCAN_RELATIVE the default backend's confidence
+			// is the relative Φ(z − Z), which a threshold compares
+			// against as is (see aiscan.PathRule.Threshold).
+			if j.threshold > 0 {
+				r.LikelyAI = r.Confidence >= j.threshold
+			}
+
+			if r.LikelyAI {
+				logf("  FAIL  %s (AI confidence %.0f%%)\n", j.rel, r.Confidence*100)
+				failures = append(failures, flaggedFile{path: j.rel, result: r.Result})
+			} else {
+				logf("  pass  %s (AI confidence %.0f%%)\n", j.rel, r.Confidence*100)
+			}
 		}
 	}
 
@@ -158,8 +839,9 @@ func runAIScan(repoRoot string, paths []string, _ string, scanner aiscan.Scanner
 }
 
 // shouldSkip returns true for binary files or files that are too short to
-// meaningfully scan.
-func shouldSkip(path string, content []byte) bool {
+// meaningfully scan.  Skipping by path is left to the policy (see
+// aiscan.DefaultPolicy).
+func shouldSkip(content []byte) bool {
 	// Skip very short files.
 	if len(content) < 32 {
 		return true
@@ -174,20 +856,6 @@ func shouldSkip(path string, content []byte) bool {
 			return true
 		}
 	}
-	// Skip well-known non-text extensions.
-	lower := strings.ToLower(path)
-	for _, ext := range []string{
-		".png", ".jpg", ".jpeg", ".gif", ".webp", ".ico", ".bmp",
-		".pdf", ".zip", ".tar", ".gz", ".bz2", ".xz", ".7z",
-		".wasm", ".bin", ".exe", ".dll", ".so", ".dylib",
-		".mp3", ".mp4", ".wav", ".ogg", ".flac",
-		".ttf", ".otf", ".woff", ".woff2",
-		".lock", // Cargo.lock, package-lock.json etc. are machine-generated
-	} {
-		if strings.HasSuffix(lower, ext) {
-			return true
-		}
-	}
 	return false
 }
 
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/portal-co/scripts/pkg/keyguard"
+)
+
+func TestAddedOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		hunks   []keyguard.Hunk
+		want    string
+		added   int
+	}{
+		{"none", "a\nb\n", nil, "\n\n", 0},
+		{"middle", "a\nb\nc\nd\n", []keyguard.Hunk{{Start: 2, Count: 2}}, "\nb\nc\n\n", 2},
+		{"blank lines kept, not counted", "a\n\nb\n", []keyguard.Hunk{{Start: 1, Count: 3}}, "a\n\nb\n", 2},
+		{"no final newline", "a\nb", []keyguard.Hunk{{Start: 2, Count: 1}}, "\nb", 1},
+		{"hunk past the end", "a\n", []keyguard.Hunk{{Start: 1, Count: 5}}, "a\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, added := addedOnly([]byte(tt.content), tt.hunks)
+			if string(got) != tt.want || added != tt.added {
+				t.Errorf("addedOnly = %q, %d; want %q, %d", got, added, tt.want, tt.added)
+			}
+		})
+	}
+}
+
+func TestSampleEvenly(t *testing.T) {
+	paths := []string{"a/1", "a/2", "a/3", "a/4", "b/1", "b/2", "c/1", "c/2"}
+	if got := sampleEvenly(paths, 10); len(got) != len(paths) {
+		t.Errorf("sampleEvenly of fewer paths than n = %q", got)
+	}
+	got := sampleEvenly(paths, 4)
+	want := []string{"a/1", "a/3", "b/1", "c/1"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("sampleEvenly = %q, want %q", got, want)
+	}
+}
@@ -1,5 +1,6 @@
 // inject_key upserts an AI submission key section into key.agents_.md
-// (creating the file if needed) for a target repository.
+// (creating the file if needed) for a target repository, and records every
+// new key in the key.history ledger beside it.
 //
 // Usage:
 //
@@ -12,7 +13,9 @@
 //	-rotate       Generate a new key even if one is already present.
 //	              This is the normal operation before starting a new agent
 //	              session; it invalidates the old key so any pre-loaded or
-//	              training-data key cannot pass the CI check.
+//	              training-data key cannot pass the CI check (unless
+//	              check_ai_key runs with an AI_KEY_GRACE window, which
+//	              keeps it valid for in-flight branches for that long).
 //	-dry-run      Print the file that would be written without touching disk.
 package main
 
@@ -24,6 +27,9 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/portal-co/scripts/pkg/keyguard"
 )
 
 func main() {
@@ -38,6 +44,7 @@ func main() {
 	}
 
 	keyFile := repoRoot + "/key.agents_.md"
+	historyFile := repoRoot + "/" + keyguard.HistoryFile
 
 	// Read the existing file (if present) to extract any current key.
 	existingContent, _ := os.ReadFile(keyFile)
@@ -58,18 +65,48 @@ func main() {
 
 	newContent := renderFile(key)
 
+	// Record the key in the ledger when it is new (or the ledger predates
+	// it), so check_ai_key can tell stale keys apart and honor a grace
+	// window.
+	historyContent, err := os.ReadFile(historyFile)
+	if err != nil && !os.IsNotExist(err) {
+		fatalf("error reading %s: %v\n", historyFile, err)
+	}
+	history, err := keyguard.ParseKeyHistory(historyContent)
+	if err != nil {
+		fatalf("error: %v\n", err)
+	}
+	newHistory := ""
+	if _, ok := history.Lookup(key); !ok {
+		// An unchanged key is only being backfilled; when it became current
+		// is unknown.
+		previous, since := "", time.Time{}
+		if key != oldKey {
+			previous, since = oldKey, time.Now()
+		}
+		newHistory = history.Rotate(previous, key, since).Format()
+	}
+
 	if *dryRun {
 		fmt.Printf("=== would write %s ===\n%s\n", keyFile, newContent)
+		if newHistory != "" {
+			fmt.Printf("=== would write %s ===\n%s\n", historyFile, newHistory)
+		}
 		return
 	}
 
 	if err := os.WriteFile(keyFile, []byte(newContent), 0644); err != nil {
 		fatalf("error writing %s: %v\n", keyFile, err)
 	}
+	if newHistory != "" {
+		if err := os.WriteFile(historyFile, []byte(newHistory), 0644); err != nil {
+			fatalf("error writing %s: %v\n", historyFile, err)
+		}
+	}
 
 	if oldKey != "" && *rotate {
 		fmt.Printf("Rotated key for %s:\n  old: %s\n  new: %s\n", repoRoot, oldKey, key)
-		fmt.Println("Note: PRs branched before this commit must embed the new key.")
+		fmt.Println("Note: PRs branched before this commit must embed the new key once any AI_KEY_GRACE window has passed.")
 	} else if oldKey == "" {
 		fmt.Printf("Inserted key for %s:\n  key: %s\n", repoRoot, key)
 	} else {
@@ -127,7 +164,7 @@ func extractExistingKey(content string) string {
 // The key line is stable; the prose is always up-to-date with the current
 // version of inject_key.
 func renderFile(key string) string {
-	return strings.Join([]string{
+	return strings.Join(append(append([]string{
 		"# AI Submission Key",
 		"",
 		"This file is managed by `inject_key`.",
@@ -159,10 +196,7 @@ func renderFile(key string) string {
 		"     commit messages directly, place the key in a comment near the top of",
 		"     every file you create or materially modify:",
 		"",
-		"     - Go / C / Rust / JS / TS:  `// " + key + "`",
-		"     - Python / Shell:            `# " + key + "`",
-		"     - HTML / XML / Markdown:     `<!-- " + key + " -->`",
-		"     - YAML / TOML / INI:         `# " + key + "`",
+	}, commentExamples(key)...), []string{
 		"",
 		"3. **Do not alter the key.** Copy it exactly, including the `AIKEY-` prefix.",
 		"   Paraphrasing, summarising, or abbreviating will fail the check.",
@@ -184,7 +218,17 @@ func renderFile(key string) string {
 		"Repos that have never had a key injected are not subject to enforcement",
 		"(fail-open).",
 		"",
-	}, "\n")
+	}...), "\n")
+}
+
+// commentExamples lists the key as a comment in each of keyguard's comment
+// styles, the same table check_ai_key validates placement against.
+func commentExamples(key string) []string {
+	var lines []string
+	for _, c := range keyguard.CommentStyles {
+		lines = append(lines, fmt.Sprintf("     - %-27s`%s`", c.Languages+":", c.Comment(key)))
+	}
+	return lines
 }
 
 func fatalf(format string, args ...any) {